@@ -0,0 +1,141 @@
+// Package auth attaches credentials to the outbound requests of an HttpClient.
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/middleware"
+	jsonHandler "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// Provider adds credentials to a request.
+type Provider interface {
+	Apply(req *http.Request) error
+}
+
+// Invalidator is implemented by providers whose credentials can go stale before they expire.
+// After a 401 the request is sent once more with fresh credentials. rejected is the request as it was sent, so
+// credentials that were replaced meanwhile, e.g. by a concurrent request that got a 401 as well, are kept.
+type Invalidator interface {
+	Invalidate(rejected *http.Request)
+}
+
+type bearerProvider struct {
+	token string
+}
+
+// NewBearerProvider sends token as "Authorization: Bearer <token>".
+func NewBearerProvider(token string) Provider {
+	return &bearerProvider{token: token}
+}
+
+func (p *bearerProvider) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	return nil
+}
+
+type basicProvider struct {
+	username string
+	password string
+}
+
+// NewBasicProvider uses HTTP basic authentication.
+func NewBasicProvider(username string, password string) Provider {
+	return &basicProvider{username: username, password: password}
+}
+
+func (p *basicProvider) Apply(req *http.Request) error {
+	req.SetBasicAuth(p.username, p.password)
+	return nil
+}
+
+type apiKeyProvider struct {
+	header string
+	key    string
+}
+
+// NewAPIKeyProvider sends key in header, e.g. "X-API-Key".
+func NewAPIKeyProvider(header string, key string) Provider {
+	return &apiKeyProvider{header: header, key: key}
+}
+
+func (p *apiKeyProvider) Apply(req *http.Request) error {
+	req.Header.Set(p.header, p.key)
+	return nil
+}
+
+// NewProviderFromConfig creates the provider selected by AuthType, or returns nil if no AuthType is set.
+// The OAuth2 token endpoint is called with tokenClient, which should not carry any credentials itself.
+func NewProviderFromConfig(config util.InfrastructureConfig, tokenClient httpclient.HttpClient, errorUtil errorHelper.Helper, jsonHandler jsonHandler.JSONHandler) (Provider, error) {
+	switch config.AuthType {
+	case "":
+		return nil, nil
+	case "bearer":
+		return NewBearerProvider(config.AuthToken), nil
+	case "basic":
+		return NewBasicProvider(config.AuthUsername, config.AuthPassword), nil
+	case "api_key":
+		header := config.AuthAPIKeyHeader
+		if header == "" {
+			header = "X-API-Key"
+		}
+		return NewAPIKeyProvider(header, config.AuthAPIKey), nil
+	case "oauth2":
+		if config.AuthTokenURL == "" {
+			return nil, errorUtil.New("oauth2 authentication needs a token URL")
+		}
+		return NewClientCredentialsProvider(tokenClient, errorUtil, jsonHandler, config.AuthTokenURL,
+			config.AuthClientID, config.AuthClientSecret, config.AuthScopes), nil
+	}
+	return nil, errorUtil.New(fmt.Sprintf("unknown authentication type %q", config.AuthType))
+}
+
+// NewAuthMiddleware returns a Middleware that lets provider add credentials to every request.
+func NewAuthMiddleware(provider Provider) middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			// The caller's request must not be changed, it may be sent again with other credentials
+			authorized := req.Clone(req.Context())
+			if err := provider.Apply(authorized); err != nil {
+				return nil, err
+			}
+
+			resp, err := next(authorized)
+			invalidator, canInvalidate := provider.(Invalidator)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized || !canInvalidate {
+				return resp, err
+			}
+
+			// Without a way to recreate the body the request can't be repeated
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return resp, nil
+			}
+
+			retry := req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				retry.Body = body
+			}
+
+			invalidator.Invalidate(authorized)
+			if err := provider.Apply(retry); err != nil {
+				return resp, nil
+			}
+
+			resp.Body.Close()
+			return next(retry)
+		}
+	}
+}
+
+// NewAuthClient wraps client with the auth middleware only.
+func NewAuthClient(client httpclient.HttpClient, provider Provider) httpclient.HttpClient {
+	return middleware.NewChainClient(client, NewAuthMiddleware(provider))
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	json "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler/json"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"gotest.tools/assert"
+)
+
+func TestStaticProviders(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     util.InfrastructureConfig
+		wantHeader string
+		wantValue  string
+	}{
+		{
+			name:       "Bearer",
+			config:     util.InfrastructureConfig{AuthType: "bearer", AuthToken: "token"},
+			wantHeader: "Authorization",
+			wantValue:  "Bearer token",
+		},
+		{
+			name:       "Basic",
+			config:     util.InfrastructureConfig{AuthType: "basic", AuthUsername: "user", AuthPassword: "pass"},
+			wantHeader: "Authorization",
+			wantValue:  "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:       "API-Key",
+			config:     util.InfrastructureConfig{AuthType: "api_key", AuthAPIKey: "key"},
+			wantHeader: "X-API-Key",
+			wantValue:  "key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewProviderFromConfig(tt.config, nil, errorUtil.NewErrorUtil(), json.NewJSONHandler())
+			assert.NilError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "https://test.url.com", nil)
+			assert.NilError(t, provider.Apply(req))
+			assert.Equal(t, tt.wantValue, req.Header.Get(tt.wantHeader))
+		})
+	}
+
+	_, err := NewProviderFromConfig(util.InfrastructureConfig{AuthType: "kerberos"}, nil, errorUtil.NewErrorUtil(), json.NewJSONHandler())
+	assert.ErrorContains(t, err, "unknown authentication type")
+}
+
+func TestClientCredentials(t *testing.T) {
+	var issued int64
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "client" || secret != "secret" || r.Form.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt64(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":120}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// The API rejects token-2 once, as if it was revoked
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		seen = append(seen, r.Header.Get("Authorization")+" "+string(body))
+		if r.Header.Get("Authorization") == "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	config := util.InfrastructureConfig{
+		AuthType:         "oauth2",
+		AuthTokenURL:     tokenServer.URL,
+		AuthClientID:     "client",
+		AuthClientSecret: "secret",
+		AuthScopes:       []string{"read", "write"},
+	}
+	provider, err := NewProviderFromConfig(config, inner, errorUtil.NewErrorUtil(), json.NewJSONHandler())
+	assert.NilError(t, err)
+	now := time.Now()
+	provider.(*clientCredentialsProvider).now = func() time.Time { return now }
+	client := NewAuthClient(inner, provider)
+
+	post := func() int {
+		resp, err := client.Post(api.URL, "text/plain", []byte("body"))
+		assert.NilError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Cached until shortly before it expires
+	assert.Equal(t, 200, post())
+	assert.Equal(t, 200, post())
+	now = now.Add(91 * time.Second)
+	// Refreshed to token-2, which gets a 401, so token-3 is fetched and the request repeated
+	assert.Equal(t, 200, post())
+
+	assert.DeepEqual(t, []string{
+		"Bearer token-1 body",
+		"Bearer token-1 body",
+		"Bearer token-2 body",
+		"Bearer token-3 body",
+	}, seen)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&issued))
+}
+
+func TestClientCredentials_Concurrent401(t *testing.T) {
+	const requests = 10
+
+	var issued int64
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer"}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// All requests are rejected with token-1 together, before any of them can fetch a new one
+	var rejected sync.WaitGroup
+	rejected.Add(requests)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			rejected.Done()
+			rejected.Wait()
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	provider := NewClientCredentialsProvider(inner, errorUtil.NewErrorUtil(), json.NewJSONHandler(), tokenServer.URL, "client", "secret", nil)
+	client := NewAuthClient(inner, provider)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(api.URL)
+			assert.Check(t, err)
+			if err == nil {
+				resp.Body.Close()
+				assert.Check(t, resp.StatusCode == http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// token-1 and the one replacing it, not one per rejected request
+	assert.Equal(t, int64(2), atomic.LoadInt64(&issued))
+}
+
+func TestClientCredentials_TokenError(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer tokenServer.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	provider := NewClientCredentialsProvider(inner, errorUtil.NewErrorUtil(), json.NewJSONHandler(), tokenServer.URL, "client", "wrong", nil)
+	_, err := NewAuthClient(inner, provider).Get("https://test.url.com")
+
+	assert.Assert(t, strings.Contains(err.Error(), "invalid_client"), err)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	jsonHandler "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler"
+)
+
+// maxTokenResponseBytes is more than any token response needs, the rest isn't read
+const maxTokenResponseBytes = 1 << 20
+
+// refreshLeeway is how long before its expiry a token is replaced, to not have it expire on the way
+const refreshLeeway = 30 * time.Second
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type clientCredentialsProvider struct {
+	client       httpclient.HttpClient
+	errorUtil    errorHelper.Helper
+	jsonHandler  jsonHandler.JSONHandler
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	now          func() time.Time
+
+	mutex     sync.Mutex
+	token     string
+	tokenType string
+	// refreshAt is zero for tokens without expiry
+	refreshAt time.Time
+}
+
+// NewClientCredentialsProvider fetches access tokens with the OAuth2 client credentials grant (RFC 6749 section 4.4).
+// A token is reused until shortly before it expires, or until a request using it was answered with 401.
+func NewClientCredentialsProvider(client httpclient.HttpClient, errorUtil errorHelper.Helper, jsonHandler jsonHandler.JSONHandler,
+	tokenURL string, clientID string, clientSecret string, scopes []string) Provider {
+	return &clientCredentialsProvider{
+		client:       client,
+		errorUtil:    errorUtil,
+		jsonHandler:  jsonHandler,
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		now:          time.Now,
+	}
+}
+
+func (p *clientCredentialsProvider) Apply(req *http.Request) error {
+	tokenType, token, err := p.currentToken(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", tokenType+" "+token)
+	return nil
+}
+
+func (p *clientCredentialsProvider) Invalidate(rejected *http.Request) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if rejected.Header.Get("Authorization") == p.tokenType+" "+p.token {
+		p.token = ""
+	}
+}
+
+// currentToken holds the lock while fetching, so concurrent requests wait for the same token
+func (p *clientCredentialsProvider) currentToken(ctx context.Context) (string, string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.token != "" && (p.refreshAt.IsZero() || p.now().Before(p.refreshAt)) {
+		return p.tokenType, p.token, nil
+	}
+
+	token, err := p.fetch(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	p.token = token.AccessToken
+	// "bearer" is common in responses but the header wants it capitalized
+	p.tokenType = "Bearer"
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		p.tokenType = token.TokenType
+	}
+
+	// Without an expiry the token is kept until the server rejects it
+	p.refreshAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		lifetime := time.Duration(token.ExpiresIn) * time.Second
+		leeway := refreshLeeway
+		if lifetime/2 < leeway {
+			leeway = lifetime / 2
+		}
+		p.refreshAt = p.now().Add(lifetime - leeway)
+	}
+
+	return p.tokenType, p.token, nil
+}
+
+func (p *clientCredentialsProvider) fetch(ctx context.Context) (*tokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.scopes) > 0 {
+		form.Set("scope", strings.Join(p.scopes, " "))
+	}
+
+	req, err := httpclient.NewRequestBuilder(http.MethodPost, p.tokenURL).
+		WithContext(ctx).
+		Header("Content-Type", "application/x-www-form-urlencoded").
+		Header("Accept", "application/json").
+		Body(strings.NewReader(form.Encode())).
+		Build()
+	if err != nil {
+		return nil, p.errorUtil.WithStack(err)
+	}
+	req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, p.errorUtil.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTokenResponseBytes))
+	if err != nil {
+		return nil, p.errorUtil.WithStack(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.errorUtil.New(fmt.Sprintf("token endpoint responded with status %d: %s", resp.StatusCode, body))
+	}
+
+	var token tokenResponse
+	if err := p.jsonHandler.Unmarshal(body, &token); err != nil {
+		return nil, p.errorUtil.WithStack(err)
+	}
+	if token.AccessToken == "" {
+		return nil, p.errorUtil.New("token endpoint responded without an access token")
+	}
+
+	return &token, nil
+}
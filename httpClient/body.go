@@ -0,0 +1,37 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+type closeNotifyingBody struct {
+	io.ReadCloser
+	once   sync.Once
+	closed func()
+}
+
+// OnClose returns body calling closed once it was closed the first time, for decorators that hold on to
+// something, like a slot or a context, for as long as the caller reads the response.
+func OnClose(body io.ReadCloser, closed func()) io.ReadCloser {
+	return &closeNotifyingBody{ReadCloser: body, closed: closed}
+}
+
+func (b *closeNotifyingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.closed)
+	return err
+}
+
+// IsIdempotent tells whether sending req more than once has the same effect as sending it once. Besides the
+// idempotent methods that are requests carrying an Idempotency-Key header, the same convention net/http uses
+// for its own retries.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	return hasKey
+}
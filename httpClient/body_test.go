@@ -0,0 +1,44 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestOnClose(t *testing.T) {
+	var closed int
+	body := OnClose(ioutil.NopCloser(strings.NewReader("body")), func() { closed++ })
+
+	content, err := ioutil.ReadAll(body)
+	assert.NilError(t, err)
+	assert.Equal(t, "body", string(content))
+	assert.Equal(t, 0, closed)
+
+	body.Close()
+	body.Close()
+	assert.Equal(t, 1, closed)
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		want   bool
+	}{
+		{name: "Get", method: http.MethodGet, want: true},
+		{name: "Delete", method: http.MethodDelete, want: true},
+		{name: "Post", method: http.MethodPost, want: false},
+		{name: "Post-Idempotency-Key", method: http.MethodPost, header: http.Header{"Idempotency-Key": {"1"}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsIdempotent(&http.Request{Method: tt.method, Header: tt.header}))
+		})
+	}
+}
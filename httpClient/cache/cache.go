@@ -0,0 +1,444 @@
+// Package cache wraps an HttpClient with a private HTTP cache following RFC 9111.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// Stats counts how requests were answered.
+type Stats struct {
+	// Hits were served from the cache without contacting the server
+	Hits uint64
+	// Revalidations were confirmed with a 304 Not Modified and then served from the cache
+	Revalidations uint64
+	// Misses had to be fetched from the server
+	Misses uint64
+}
+
+// Client is an HttpClient that also reports how well its cache works.
+type Client interface {
+	httpclient.HttpClient
+	Stats() Stats
+}
+
+// maxBodyBytes keeps huge responses out of the cache, they are passed through instead
+const maxBodyBytes = 1 << 20
+
+// statuses that may be cached without explicit freshness information
+var heuristicallyCacheable = map[int]bool{
+	http.StatusOK: true, http.StatusNonAuthoritativeInfo: true, http.StatusNoContent: true,
+	http.StatusMultipleChoices: true, http.StatusMovedPermanently: true, http.StatusPermanentRedirect: true,
+	http.StatusNotFound: true, http.StatusMethodNotAllowed: true, http.StatusGone: true,
+	http.StatusRequestURITooLong: true, http.StatusNotImplemented: true,
+}
+
+type cacheClient struct {
+	client httpclient.HttpClient
+	store  Store
+	now    func() time.Time
+
+	hits          uint64
+	revalidations uint64
+	misses        uint64
+}
+
+// NewCacheClient returns a Client that answers GET requests from store while they are fresh and revalidates them
+// with their ETag or Last-Modified once they are stale. Cached requests are sent with client.Do, so they can
+// carry the conditional headers. Requests can ask for fresher or accept staler responses with the Cache-Control
+// directives max-age, min-fresh and max-stale.
+func NewCacheClient(client httpclient.HttpClient, store Store) Client {
+	return &cacheClient{
+		client: client,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+func (c *cacheClient) Get(url string) (*http.Response, error) {
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *cacheClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	resp, err := c.client.Post(url, contentType, body)
+	c.invalidate(cacheKey(url), resp, err)
+	return resp, err
+}
+
+func (c *cacheClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).WithContext(ctx).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *cacheClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	resp, err := c.client.PostWithContext(ctx, url, contentType, body)
+	c.invalidate(cacheKey(url), resp, err)
+	return resp, err
+}
+
+func (c *cacheClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != "" {
+		resp, err := c.client.Do(req)
+		if !isSafe(req.Method) {
+			c.invalidate(req.URL.String(), resp, err)
+		}
+		return resp, err
+	}
+
+	requestDirectives := parseCacheControl(req.Header)
+	if _, noStore := requestDirectives["no-store"]; noStore {
+		atomic.AddUint64(&c.misses, 1)
+		return c.client.Do(req)
+	}
+
+	key := req.URL.String()
+	entry, ok := c.store.Get(key)
+	if ok && !entry.matchesVary(req) {
+		ok = false
+	}
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return c.fetch(key, req)
+	}
+
+	_, noCache := requestDirectives["no-cache"]
+	if !noCache && c.isFresh(entry, requestDirectives) {
+		atomic.AddUint64(&c.hits, 1)
+		return c.response(req, entry), nil
+	}
+
+	return c.revalidate(key, req, entry)
+}
+
+func (c *cacheClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *cacheClient) Stats() Stats {
+	return Stats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Revalidations: atomic.LoadUint64(&c.revalidations),
+		Misses:        atomic.LoadUint64(&c.misses),
+	}
+}
+
+// fetch sends req and stores the response if it may be cached
+func (c *cacheClient) fetch(key string, req *http.Request) (*http.Response, error) {
+	requestTime := c.now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	c.save(key, req, resp, requestTime)
+	return resp, nil
+}
+
+func (c *cacheClient) revalidate(key string, req *http.Request, entry *Entry) (*http.Response, error) {
+	etag := entry.Header.Get("ETag")
+	lastModified := entry.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		atomic.AddUint64(&c.misses, 1)
+		return c.fetch(key, req)
+	}
+
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := c.now()
+	resp, err := c.client.Do(conditional)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		atomic.AddUint64(&c.misses, 1)
+		c.save(key, req, resp, requestTime)
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	// The 304 carries the up to date metadata for the stored body
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range resp.Header {
+		if name != "Content-Length" {
+			updated.Header[name] = values
+		}
+	}
+	updated.RequestTime = requestTime
+	updated.ResponseTime = c.now()
+	c.store.Set(key, &updated)
+
+	atomic.AddUint64(&c.revalidations, 1)
+	return c.response(req, &updated), nil
+}
+
+// save keeps resp if it is cacheable, replacing its body with one reading from memory
+func (c *cacheClient) save(key string, req *http.Request, resp *http.Response, requestTime time.Time) {
+	if !c.isCacheable(req, resp) {
+		return
+	}
+
+	if resp.ContentLength > maxBodyBytes {
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil || len(body) > maxBodyBytes {
+		// Hand the caller what was read so far followed by the rest, without caching it
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := &Entry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: c.now(),
+		Vary:         map[string]string{},
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		entry.Vary[name] = req.Header.Get(name)
+	}
+	c.store.Set(key, entry)
+}
+
+func (c *cacheClient) isCacheable(req *http.Request, resp *http.Response) bool {
+	if _, noStore := parseCacheControl(req.Header)["no-store"]; noStore {
+		return false
+	}
+
+	directives := parseCacheControl(resp.Header)
+	if _, noStore := directives["no-store"]; noStore {
+		return false
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	if heuristicallyCacheable[resp.StatusCode] {
+		return true
+	}
+	// Other statuses need explicit freshness
+	_, hasMaxAge := directives["max-age"]
+	return resp.StatusCode < 500 && (hasMaxAge || resp.Header.Get("Expires") != "")
+}
+
+// isFresh tells whether entry may be served without asking the server, taking into account what the request
+// allows with its max-age, min-fresh and max-stale directives, RFC 9111 section 5.2.1
+func (c *cacheClient) isFresh(entry *Entry, requestDirectives map[string]string) bool {
+	directives := parseCacheControl(entry.Header)
+	if _, noCache := directives["no-cache"]; noCache {
+		return false
+	}
+
+	age := c.age(entry)
+	lifetime := freshnessLifetime(entry, directives)
+	if maxAge, ok := requestDirectives["max-age"]; ok && age > seconds(maxAge) {
+		return false
+	}
+	if minFresh, ok := requestDirectives["min-fresh"]; ok {
+		return lifetime-age >= seconds(minFresh)
+	}
+	if age < lifetime {
+		return true
+	}
+
+	// The server may forbid serving its response stale, whatever the request allows
+	if _, mustRevalidate := directives["must-revalidate"]; mustRevalidate {
+		return false
+	}
+	maxStale, ok := requestDirectives["max-stale"]
+	return ok && (maxStale == "" || age-lifetime <= seconds(maxStale))
+}
+
+// age follows the calculation of RFC 9111 section 4.2.3
+func (c *cacheClient) age(entry *Entry) time.Duration {
+	apparentAge := time.Duration(0)
+	if date, err := http.ParseTime(entry.Header.Get("Date")); err == nil && entry.ResponseTime.After(date) {
+		apparentAge = entry.ResponseTime.Sub(date)
+	}
+
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.Atoi(entry.Header.Get("Age")); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAgeValue := ageValue + entry.ResponseTime.Sub(entry.RequestTime)
+
+	initialAge := apparentAge
+	if correctedAgeValue > initialAge {
+		initialAge = correctedAgeValue
+	}
+	return initialAge + c.now().Sub(entry.ResponseTime)
+}
+
+// freshnessLifetime follows RFC 9111 section 4.2.1, using the suggested 10% heuristic for Last-Modified
+func freshnessLifetime(entry *Entry, directives map[string]string) time.Duration {
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, dateErr := http.ParseTime(entry.Header.Get("Date"))
+	if dateErr != nil {
+		date = entry.ResponseTime
+	}
+
+	if expiresValue := entry.Header.Get("Expires"); expiresValue != "" {
+		// An invalid Expires, like "0", means already expired
+		expires, err := http.ParseTime(expiresValue)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(entry.Header.Get("Last-Modified")); err == nil && heuristicallyCacheable[entry.StatusCode] {
+		return date.Sub(lastModified) / 10
+	}
+	return 0
+}
+
+func (c *cacheClient) response(req *http.Request, entry *Entry) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(c.age(entry).Seconds())))
+
+	return &http.Response{
+		Status:        strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// invalidate drops the stored responses for key and for the Location and Content-Location of the response after
+// a successful unsafe request to it, RFC 9111 section 4.4. Locations of another origin are left alone, as the
+// server of key has no say over them.
+func (c *cacheClient) invalidate(key string, resp *http.Response, err error) {
+	if err != nil || resp == nil || resp.StatusCode >= 400 {
+		return
+	}
+	c.store.Delete(key)
+
+	target, err := url.Parse(key)
+	if err != nil {
+		return
+	}
+	for _, name := range []string{"Location", "Content-Location"} {
+		value := resp.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		location, err := target.Parse(value)
+		if err != nil || location.Scheme != target.Scheme || location.Host != target.Host {
+			continue
+		}
+		c.store.Delete(cacheKey(location.String()))
+	}
+}
+
+// cacheKey returns the key a GET of url is stored under, which is the URL after GetWithContext built the request
+func cacheKey(url string) string {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).Build()
+	if err != nil {
+		return url
+	}
+	return req.URL.String()
+}
+
+// seconds parses the value of a directive like max-age, an invalid one counts as 0
+func seconds(value string) time.Duration {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+func (e *Entry) matchesVary(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// parseCacheControl returns the directives in lower case, mapped to their unquoted value if they have one
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			parts := strings.SplitN(directive, "=", 2)
+			name := strings.ToLower(strings.TrimSpace(parts[0]))
+			if len(parts) == 2 {
+				directives[name] = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+			} else {
+				directives[name] = ""
+			}
+		}
+	}
+	return directives
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	"gotest.tools/assert"
+)
+
+func TestCacheClient(t *testing.T) {
+	var requests, notModified int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt64(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		}
+		fmt.Fprintf(w, "body of %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	client := NewCacheClient(inner, NewMemoryStore(10, 0))
+	now := time.Now()
+	client.(*cacheClient).now = func() time.Time { return now }
+
+	get := func(path string) string {
+		resp, err := client.Get(server.URL + path)
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return string(body)
+	}
+
+	// Fresh for a minute, then fetched again
+	assert.Equal(t, "body of /fresh", get("/fresh"))
+	assert.Equal(t, "body of /fresh", get("/fresh"))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+	now = now.Add(61 * time.Second)
+	assert.Equal(t, "body of /fresh", get("/fresh"))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+
+	// Always revalidated, the body comes from the cache
+	assert.Equal(t, "body of /etag", get("/etag"))
+	assert.Equal(t, "body of /etag", get("/etag"))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&notModified))
+
+	// Never stored
+	get("/no-store")
+	get("/no-store")
+	assert.Equal(t, int64(6), atomic.LoadInt64(&requests))
+
+	// A successful POST drops the stored response
+	resp, err := client.Post(server.URL+"/fresh", "text/plain", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	get("/fresh")
+	assert.Equal(t, int64(8), atomic.LoadInt64(&requests))
+
+	assert.Equal(t, Stats{Hits: 1, Revalidations: 1, Misses: 6}, client.Stats())
+}
+
+func TestCacheClient_PostInvalidatesNormalizedURL(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer server.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	client := NewCacheClient(inner, NewMemoryStore(10, 0))
+
+	// The GET is stored under the encoded URL, the POST has to find it by the same key
+	url := server.URL + "/items list?b=2&a=1"
+	for _, send := range []func() (*http.Response, error){
+		func() (*http.Response, error) { return client.Get(url) },
+		func() (*http.Response, error) { return client.Post(url, "text/plain", nil) },
+		func() (*http.Response, error) { return client.Get(url) },
+	} {
+		resp, err := send()
+		assert.NilError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+}
+
+func TestMemoryStore_Evicts(t *testing.T) {
+	store := NewMemoryStore(2, 0)
+	store.Set("a", &Entry{})
+	store.Set("b", &Entry{})
+	store.Get("a")
+	store.Set("c", &Entry{})
+
+	_, ok := store.Get("b")
+	assert.Assert(t, !ok)
+	_, ok = store.Get("a")
+	assert.Assert(t, ok)
+}
+
+func TestMemoryStore_EvictsByBytes(t *testing.T) {
+	store := NewMemoryStore(10, 250)
+	store.Set("a", &Entry{Body: make([]byte, 100)})
+	store.Set("b", &Entry{Body: make([]byte, 100)})
+	store.Get("a")
+	store.Set("c", &Entry{Body: make([]byte, 100)})
+
+	_, ok := store.Get("b")
+	assert.Assert(t, !ok)
+	_, ok = store.Get("a")
+	assert.Assert(t, ok)
+
+	// Too large on its own, nothing else is evicted for it
+	store.Set("d", &Entry{Body: make([]byte, 300)})
+	_, ok = store.Get("d")
+	assert.Assert(t, !ok)
+	_, ok = store.Get("c")
+	assert.Assert(t, ok)
+}
+
+func TestDiskStore(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 0, 0)
+	assert.NilError(t, err)
+
+	entry := &Entry{StatusCode: 200, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte("body")}
+	store.Set("https://test.url.com", entry)
+
+	stored, ok := store.Get("https://test.url.com")
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, entry.Body, stored.Body)
+	assert.Equal(t, `"v1"`, stored.Header.Get("ETag"))
+
+	store.Delete("https://test.url.com")
+	_, ok = store.Get("https://test.url.com")
+	assert.Assert(t, !ok)
+}
+
+func TestDiskStore_Evicts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 2, 0)
+	assert.NilError(t, err)
+	store.Set("a", &Entry{})
+	store.Set("b", &Entry{})
+	store.Get("a")
+	store.Set("c", &Entry{})
+
+	_, ok := store.Get("b")
+	assert.Assert(t, !ok)
+	_, ok = store.Get("a")
+	assert.Assert(t, ok)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 2, len(files))
+
+	// Reopened with a smaller limit, the file written last is kept
+	store, err = NewDiskStore(dir, 1, 0)
+	assert.NilError(t, err)
+	_, ok = store.Get("c")
+	assert.Assert(t, ok)
+	files, _ = ioutil.ReadDir(dir)
+	assert.Equal(t, 1, len(files))
+
+	// Too large on its own
+	store, err = NewDiskStore(t.TempDir(), 0, 1)
+	assert.NilError(t, err)
+	store.Set("d", &Entry{Body: []byte("too large")})
+	_, ok = store.Get("d")
+	assert.Assert(t, !ok)
+}
+
+func TestCacheClient_RequestDirectives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/must-revalidate" {
+			w.Header().Set("Cache-Control", "max-age=60, must-revalidate")
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		age          time.Duration
+		cacheControl string
+		wantCached   bool
+	}{
+		{name: "Successful--Max-Age", age: 20 * time.Second, cacheControl: "max-age=30", wantCached: true},
+		{name: "Successful--Min-Fresh", age: 20 * time.Second, cacheControl: "min-fresh=30", wantCached: true},
+		{name: "Successful--Max-Stale", age: 70 * time.Second, cacheControl: "max-stale=30", wantCached: true},
+		{name: "Successful--Max-Stale-Without-Limit", age: time.Hour, cacheControl: "max-stale", wantCached: true},
+		{name: "Failed--Max-Age", age: 40 * time.Second, cacheControl: "max-age=30"},
+		{name: "Failed--Min-Fresh", age: 40 * time.Second, cacheControl: "min-fresh=30"},
+		{name: "Failed--Max-Stale", age: 100 * time.Second, cacheControl: "max-stale=30"},
+		{name: "Failed--Max-Stale-Must-Revalidate", path: "/must-revalidate", age: 70 * time.Second, cacheControl: "max-stale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, _ := nethttp.NewNetHttpClient()
+			client := NewCacheClient(inner, NewMemoryStore(10, 0))
+			now := time.Now()
+			client.(*cacheClient).now = func() time.Time { return now }
+
+			get := func(cacheControl string) {
+				req, err := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+				assert.NilError(t, err)
+				req.Header.Set("Cache-Control", cacheControl)
+				resp, err := client.Do(req)
+				assert.NilError(t, err)
+				resp.Body.Close()
+			}
+
+			get("")
+			now = now.Add(tt.age)
+			get(tt.cacheControl)
+			assert.Equal(t, tt.wantCached, client.Stats().Hits == 1)
+		})
+	}
+}
+
+func TestCacheClient_InvalidatesLocations(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", "/items/1")
+			w.Header().Set("Content-Location", "https://other.url.com/items")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer server.Close()
+
+	inner, _ := nethttp.NewNetHttpClient()
+	store := NewMemoryStore(10, 0)
+	store.Set("https://other.url.com/items", &Entry{StatusCode: http.StatusOK})
+	client := NewCacheClient(inner, store)
+
+	get := func() {
+		resp, err := client.Get(server.URL + "/items/1")
+		assert.NilError(t, err)
+		resp.Body.Close()
+	}
+	get()
+	get()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+
+	// The POST creates /items/1, so the stored response of it is outdated, the other origin is left alone
+	resp, err := client.Post(server.URL+"/items", "text/plain", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	get()
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+	_, ok := store.Get("https://other.url.com/items")
+	assert.Assert(t, ok)
+}
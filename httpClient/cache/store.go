@@ -0,0 +1,320 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a stored response together with what is needed to judge its freshness.
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// RequestTime and ResponseTime are when the request was sent and the response arrived, used for the age
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// Vary holds the request header values named by the Vary response header
+	Vary map[string]string
+}
+
+// Store keeps entries by key, implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+}
+
+type memoryStore struct {
+	maxEntries int
+	maxBytes   int64
+
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// NewMemoryStore returns a Store holding up to maxEntries entries of together up to maxBytes, dropping the least
+// recently used ones first. Entries larger than maxBytes on their own are not stored. The defaults are 1000 entries
+// and 64 MiB.
+func NewMemoryStore(maxEntries int, maxBytes int64) Store {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+
+	return &memoryStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (s *memoryStore) Get(key string) (*Entry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, true
+}
+
+func (s *memoryStore) Set(key string, entry *Entry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+
+	size := entrySize(key, entry)
+	if size > s.maxBytes {
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryItem{key: key, entry: entry, size: size})
+	s.size += size
+	for s.order.Len() > s.maxEntries || s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *memoryStore) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+}
+
+// remove must be called with the mutex held
+func (s *memoryStore) remove(element *list.Element) {
+	item := element.Value.(*memoryItem)
+	s.order.Remove(element)
+	delete(s.entries, item.key)
+	s.size -= item.size
+}
+
+// entrySize estimates the memory an entry takes by its body, headers and key
+func entrySize(key string, entry *Entry) int64 {
+	size := int64(len(key) + len(entry.Body))
+	for name, values := range entry.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	for name, value := range entry.Vary {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+type diskStore struct {
+	dir        string
+	maxEntries int
+	maxBytes   int64
+
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+type diskItem struct {
+	name string
+	size int64
+}
+
+// NewDiskStore returns a Store keeping one gob encoded file per entry in dir, which is created if needed.
+// Like NewMemoryStore it holds up to maxEntries files of together up to maxBytes, deleting the least recently
+// used ones first, by default 10000 entries and 1 GiB. Files already in dir count as used when they were last
+// written. Entries that can't be read back are treated as missing.
+func NewDiskStore(dir string, maxEntries int, maxBytes int64) (Store, error) {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	if maxBytes <= 0 {
+		maxBytes = 1 << 30
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	s := &diskStore{
+		dir:        dir,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		// Left behind by a Set that never finished
+		if strings.HasPrefix(file.Name(), "entry-") {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+		s.entries[file.Name()] = s.order.PushFront(&diskItem{name: file.Name(), size: file.Size()})
+		s.size += file.Size()
+	}
+	s.mutex.Lock()
+	s.evict()
+	s.mutex.Unlock()
+	return s, nil
+}
+
+func (s *diskStore) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *diskStore) Get(key string) (*Entry, bool) {
+	name := s.name(key)
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	var entry Entry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil {
+		return nil, false
+	}
+
+	s.mutex.Lock()
+	if element, ok := s.entries[name]; ok {
+		s.order.MoveToFront(element)
+	}
+	s.mutex.Unlock()
+	return &entry, true
+}
+
+func (s *diskStore) Set(key string, entry *Entry) {
+	// Write to a temporary file first, so readers never see half an entry
+	file, err := ioutil.TempFile(s.dir, "entry-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	if err := gob.NewEncoder(file).Encode(entry); err != nil {
+		file.Close()
+		return
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+	if err := file.Close(); err != nil || info.Size() > s.maxBytes {
+		return
+	}
+
+	name := s.name(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Rename(file.Name(), filepath.Join(s.dir, name)); err != nil {
+		return
+	}
+	if element, ok := s.entries[name]; ok {
+		s.forget(element)
+	}
+	s.entries[name] = s.order.PushFront(&diskItem{name: name, size: info.Size()})
+	s.size += info.Size()
+	s.evict()
+}
+
+func (s *diskStore) Delete(key string) {
+	name := s.name(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	os.Remove(filepath.Join(s.dir, name))
+	if element, ok := s.entries[name]; ok {
+		s.forget(element)
+	}
+}
+
+// evict deletes the least recently used files until the store is within its limits, it must be called with the
+// mutex held
+func (s *diskStore) evict() {
+	for s.order.Len() > s.maxEntries || s.size > s.maxBytes {
+		element := s.order.Back()
+		os.Remove(filepath.Join(s.dir, element.Value.(*diskItem).name))
+		s.forget(element)
+	}
+}
+
+// forget must be called with the mutex held
+func (s *diskStore) forget(element *list.Element) {
+	item := element.Value.(*diskItem)
+	s.order.Remove(element)
+	delete(s.entries, item.name)
+	s.size -= item.size
+}
+
+type layeredStore struct {
+	fast Store
+	slow Store
+}
+
+// NewLayeredStore puts a fast store, usually from NewMemoryStore, in front of a slow one like NewDiskStore.
+// Entries found in the slow store only are copied to the fast one.
+func NewLayeredStore(fast Store, slow Store) Store {
+	return &layeredStore{fast: fast, slow: slow}
+}
+
+func (s *layeredStore) Get(key string) (*Entry, bool) {
+	if entry, ok := s.fast.Get(key); ok {
+		return entry, true
+	}
+
+	entry, ok := s.slow.Get(key)
+	if ok {
+		s.fast.Set(key, entry)
+	}
+	return entry, ok
+}
+
+func (s *layeredStore) Set(key string, entry *Entry) {
+	s.fast.Set(key, entry)
+	s.slow.Set(key, entry)
+}
+
+func (s *layeredStore) Delete(key string) {
+	s.fast.Delete(key)
+	s.slow.Delete(key)
+}
@@ -0,0 +1,295 @@
+// Package cassette records the traffic of an HttpClient to a YAML file and replays it in tests.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"gopkg.in/yaml.v2"
+)
+
+type Mode int
+
+const (
+	// Record sends every request with the wrapped client and saves it with its response
+	Record Mode = iota
+	// Replay answers requests from the cassette without any network traffic
+	Replay
+)
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `yaml:"request"`
+	Response RecordedResponse `yaml:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string      `yaml:"method"`
+	URL     string      `yaml:"url"`
+	Headers http.Header `yaml:"headers,omitempty"`
+	Body    string      `yaml:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `yaml:"status_code"`
+	Headers    http.Header `yaml:"headers,omitempty"`
+	Body       string      `yaml:"body,omitempty"`
+}
+
+// redacted replaces the values of credential headers in recorded interactions
+const redacted = "[REDACTED]"
+
+// DefaultRedactHeaders are never written to a cassette in plain text, NewRedactingRecorder adds to them.
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Auth-Token"}
+
+// NotFoundError is returned in replay mode for requests the cassette has no interaction for.
+type NotFoundError struct {
+	Method string
+	URL    string
+	Path   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no interaction for %s %s in cassette %s", e.Method, e.URL, e.Path)
+}
+
+type recorder struct {
+	client        httpclient.HttpClient
+	path          string
+	mode          Mode
+	matchers      []Matcher
+	redactHeaders map[string]bool
+
+	mutex    sync.Mutex
+	cassette Cassette
+	// used marks the replayed interactions, so repeated identical requests get the recorded responses in order
+	used []bool
+}
+
+// NewRecorder returns an HttpClient that, depending on mode, records to or replays from the cassette at path.
+// Recording starts with an empty cassette and writes the file after every request, client is not used for replay.
+// Requests are matched by method and URL unless other matchers are given.
+// The values of DefaultRedactHeaders are redacted when recording.
+func NewRecorder(client httpclient.HttpClient, path string, mode Mode, matchers ...Matcher) (httpclient.HttpClient, error) {
+	return NewRedactingRecorder(client, path, mode, nil, matchers...)
+}
+
+// NewRedactingRecorder is NewRecorder redacting redactHeaders in addition to DefaultRedactHeaders.
+// Redacted headers are recorded as "[REDACTED]", so MatchHeaders can't compare them.
+func NewRedactingRecorder(client httpclient.HttpClient, path string, mode Mode, redactHeaders []string, matchers ...Matcher) (httpclient.HttpClient, error) {
+	if len(matchers) == 0 {
+		matchers = []Matcher{MatchMethod, MatchURL}
+	}
+
+	r := &recorder{
+		client:        client,
+		path:          path,
+		mode:          mode,
+		matchers:      matchers,
+		redactHeaders: map[string]bool{},
+	}
+	for _, header := range append(DefaultRedactHeaders, redactHeaders...) {
+		r.redactHeaders[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+
+	if mode == Replay {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(content, &r.cassette); err != nil {
+			return nil, fmt.Errorf("reading cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+func (r *recorder) Get(url string) (*http.Response, error) {
+	return r.GetWithContext(context.Background(), url)
+}
+
+func (r *recorder) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return r.PostWithContext(context.Background(), url, contentType, body)
+}
+
+func (r *recorder) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).WithContext(ctx).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == Replay {
+		return r.replay(req, nil)
+	}
+	return r.record(req, nil, func() (*http.Response, error) {
+		return r.client.GetWithContext(ctx, url)
+	})
+}
+
+func (r *recorder) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodPost, url).
+		WithContext(ctx).
+		Header("Content-Type", contentType).
+		Body(bytes.NewReader(body)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == Replay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body, func() (*http.Response, error) {
+		return r.client.PostWithContext(ctx, url, contentType, body)
+	})
+}
+
+func (r *recorder) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.mode == Replay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body, func() (*http.Response, error) {
+		return r.client.Do(req)
+	})
+}
+
+func (r *recorder) Shutdown(ctx context.Context) error {
+	if r.client == nil {
+		return nil
+	}
+	return r.client.Shutdown(ctx)
+}
+
+func (r *recorder) record(req *http.Request, body []byte, send func() (*http.Response, error)) (*http.Response, error) {
+	resp, err := send()
+	if err != nil {
+		return resp, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: r.redact(req.Header),
+			Body:    string(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    r.redact(resp.Header),
+			Body:       string(responseBody),
+		},
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// redact returns a copy of header with the values of credential headers replaced
+func (r *recorder) redact(header http.Header) http.Header {
+	clone := header.Clone()
+	for name, values := range clone {
+		if r.redactHeaders[http.CanonicalHeaderKey(name)] {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+	return clone
+}
+
+// save must be called with the mutex held
+func (r *recorder) save() error {
+	content, err := yaml.Marshal(&r.cassette)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, content, 0644)
+}
+
+func (r *recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !r.matches(interaction.Request, req, body) {
+			continue
+		}
+		if !r.used[i] {
+			match = i
+			break
+		}
+		if match == -1 {
+			// All matching interactions were replayed already, keep serving the first one
+			match = i
+		}
+	}
+
+	if match == -1 {
+		return nil, &NotFoundError{Method: req.Method, URL: req.URL.String(), Path: r.path}
+	}
+	r.used[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(recorded.StatusCode) + " " + http.StatusText(recorded.StatusCode),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *recorder) matches(recorded RecordedRequest, req *http.Request, body []byte) bool {
+	for _, matcher := range r.matchers {
+		if !matcher(recorded, req, body) {
+			return false
+		}
+	}
+	return true
+}
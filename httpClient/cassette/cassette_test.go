@@ -0,0 +1,104 @@
+package cassette
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	"gotest.tools/assert"
+)
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+
+	inner, _ := nethttp.NewNetHttpClient()
+	recorder, err := NewRecorder(inner, path, Record)
+	assert.NilError(t, err)
+
+	resp, err := recorder.Post(server.URL+"/items", "application/json", []byte(`{"key":"value"}`))
+	assert.NilError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, `POST {"key":"value"}`, string(body))
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/items/1", nil)
+	_, err = recorder.Do(req)
+	assert.NilError(t, err)
+	server.Close()
+
+	content, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(content), "status_code: 201"))
+
+	// The server is gone, everything comes from the cassette now
+	replayer, err := NewRecorder(nil, path, Replay, MatchMethod, MatchURL, MatchBody, MatchHeaders("Content-Type"))
+	assert.NilError(t, err)
+
+	resp, err = replayer.Post(server.URL+"/items", "application/json", []byte(`{"key":"value"}`))
+	assert.NilError(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `POST {"key":"value"}`, string(body))
+
+	req, _ = http.NewRequest(http.MethodDelete, server.URL+"/items/1", nil)
+	_, err = replayer.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// A different body doesn't match
+	_, err = replayer.Post(server.URL+"/items", "application/json", []byte(`{"key":"other"}`))
+	var notFound *NotFoundError
+	assert.Assert(t, errors.As(err, &notFound))
+	assert.Equal(t, http.MethodPost, notFound.Method)
+}
+
+func TestNewRecorder_MissingCassette(t *testing.T) {
+	_, err := NewRecorder(nil, filepath.Join(t.TempDir(), "missing.yaml"), Replay)
+	assert.Assert(t, err != nil)
+}
+
+func TestRecorder_RedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "session-secret"})
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+
+	inner, _ := nethttp.NewNetHttpClient()
+	recorder, err := NewRedactingRecorder(inner, path, Record, []string{"X-Tenant-Secret"})
+	assert.NilError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer token-secret")
+	req.Header.Set("X-API-Key", "key-secret")
+	req.Header.Set("X-Tenant-Secret", "tenant-secret")
+	req.Header.Set("Accept", "text/plain")
+	resp, err := recorder.Do(req)
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	// The caller still gets the cookie, only the cassette doesn't
+	assert.Equal(t, "session-secret", resp.Cookies()[0].Value)
+
+	content, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+	for _, secret := range []string{"token-secret", "key-secret", "tenant-secret", "session-secret"} {
+		assert.Assert(t, !strings.Contains(string(content), secret), secret)
+	}
+	assert.Assert(t, strings.Contains(string(content), "text/plain"))
+	assert.Assert(t, strings.Contains(string(content), "'[REDACTED]'"), string(content))
+}
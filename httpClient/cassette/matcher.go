@@ -0,0 +1,32 @@
+package cassette
+
+import (
+	"net/http"
+)
+
+// Matcher decides whether a recorded request can answer req, body is the body req was sent with.
+type Matcher func(recorded RecordedRequest, req *http.Request, body []byte) bool
+
+func MatchMethod(recorded RecordedRequest, req *http.Request, body []byte) bool {
+	return recorded.Method == req.Method
+}
+
+func MatchURL(recorded RecordedRequest, req *http.Request, body []byte) bool {
+	return recorded.URL == req.URL.String()
+}
+
+func MatchBody(recorded RecordedRequest, req *http.Request, body []byte) bool {
+	return recorded.Body == string(body)
+}
+
+// MatchHeaders compares the values of the named headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(recorded RecordedRequest, req *http.Request, body []byte) bool {
+		for _, name := range names {
+			if recorded.Headers.Get(name) != req.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
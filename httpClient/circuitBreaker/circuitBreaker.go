@@ -0,0 +1,296 @@
+// Package circuitbreaker wraps an HttpClient so calls fail fast while the downstream is known to be down.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+type State int
+
+const (
+	// Closed lets every request through and tracks their outcome
+	Closed State = iota
+	// Open rejects every request until the open timeout elapsed
+	Open
+	// HalfOpen lets a limited number of probe requests through and closes once all of them succeeded
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("unknown state %d", int(s))
+}
+
+// OpenError is returned instead of sending a request while the breaker is open.
+type OpenError struct {
+	// RetryAt is the earliest time the breaker lets a probe request through again
+	RetryAt time.Time
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open until %s", e.RetryAt.Format(time.RFC3339))
+}
+
+// StateChangeFunc is called after every transition of the breaker.
+type StateChangeFunc func(from State, to State)
+
+type settings struct {
+	failureRate         float64
+	consecutiveFailures int
+	windowSize          int
+	minRequests         int
+	openTimeout         time.Duration
+	halfOpenRequests    int
+}
+
+type circuitBreakerClient struct {
+	client        httpclient.HttpClient
+	errorUtil     errorHelper.Helper
+	settings      settings
+	onStateChange []StateChangeFunc
+	now           func() time.Time
+
+	mutex sync.Mutex
+	state State
+	// generation is increased on every transition, so outcomes of requests started in an earlier state are ignored
+	generation  uint64
+	openedAt    time.Time
+	consecutive int
+	// outcomes is a ring buffer of the latest results in the closed state, true being a failure
+	outcomes []bool
+	next     int
+	filled   int
+	failures int
+	// probes counts the requests let through in the half-open state, successes the ones that came back fine
+	probes    int
+	successes int
+}
+
+// NewCircuitBreakerClient returns an HttpClient that opens after too many failures of client, as configured in config.
+// A failure is a transport error or a 5xx response.
+func NewCircuitBreakerClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig, onStateChange ...StateChangeFunc) httpclient.HttpClient {
+	s := settings{
+		failureRate:         config.CircuitBreakerFailureRate,
+		consecutiveFailures: config.CircuitBreakerConsecutiveFailures,
+		windowSize:          config.CircuitBreakerWindowSize,
+		minRequests:         config.CircuitBreakerMinRequests,
+		openTimeout:         config.CircuitBreakerOpenTimeout,
+		halfOpenRequests:    config.CircuitBreakerHalfOpenRequests,
+	}
+	if s.failureRate <= 0 || s.failureRate > 1 {
+		s.failureRate = 0.5
+	}
+	if s.consecutiveFailures <= 0 {
+		s.consecutiveFailures = 5
+	}
+	if s.windowSize <= 0 {
+		s.windowSize = 20
+	}
+	if s.minRequests <= 0 || s.minRequests > s.windowSize {
+		s.minRequests = s.windowSize / 2
+	}
+	if s.openTimeout <= 0 {
+		s.openTimeout = 30 * time.Second
+	}
+	if s.halfOpenRequests <= 0 {
+		s.halfOpenRequests = 1
+	}
+
+	return &circuitBreakerClient{
+		client:        client,
+		errorUtil:     errorUtil,
+		settings:      s,
+		onStateChange: onStateChange,
+		now:           time.Now,
+		outcomes:      make([]bool, s.windowSize),
+	}
+}
+
+func (c *circuitBreakerClient) Get(url string) (*http.Response, error) {
+	return c.call(func() (*http.Response, error) {
+		return c.client.Get(url)
+	})
+}
+
+func (c *circuitBreakerClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(func() (*http.Response, error) {
+		return c.client.Post(url, contentType, body)
+	})
+}
+
+func (c *circuitBreakerClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.call(func() (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *circuitBreakerClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(func() (*http.Response, error) {
+		return c.client.PostWithContext(ctx, url, contentType, body)
+	})
+}
+
+func (c *circuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	return c.call(func() (*http.Response, error) {
+		return c.client.Do(req)
+	})
+}
+
+func (c *circuitBreakerClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *circuitBreakerClient) call(send func() (*http.Response, error)) (*http.Response, error) {
+	generation, err := c.before()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := send()
+
+	// Giving up on our own doesn't tell anything about the health of the downstream
+	if errors.Is(err, context.Canceled) {
+		c.release(generation)
+		return resp, err
+	}
+
+	c.after(generation, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
+
+func (c *circuitBreakerClient) before() (uint64, error) {
+	c.mutex.Lock()
+	var transitions [][2]State
+	defer func() {
+		c.mutex.Unlock()
+		c.notify(transitions)
+	}()
+
+	if c.state == Open {
+		retryAt := c.openedAt.Add(c.settings.openTimeout)
+		if c.now().Before(retryAt) {
+			return 0, c.errorUtil.WithStack(&OpenError{RetryAt: retryAt})
+		}
+		transitions = append(transitions, c.setState(HalfOpen))
+	}
+
+	if c.state == HalfOpen {
+		if c.probes >= c.settings.halfOpenRequests {
+			return 0, c.errorUtil.WithStack(&OpenError{RetryAt: c.now()})
+		}
+		c.probes++
+	}
+
+	return c.generation, nil
+}
+
+func (c *circuitBreakerClient) after(generation uint64, failed bool) {
+	c.mutex.Lock()
+	var transitions [][2]State
+	defer func() {
+		c.mutex.Unlock()
+		c.notify(transitions)
+	}()
+
+	if generation != c.generation {
+		return
+	}
+
+	switch c.state {
+	case Closed:
+		c.record(failed)
+		if c.shouldTrip() {
+			transitions = append(transitions, c.setState(Open))
+		}
+	case HalfOpen:
+		if failed {
+			transitions = append(transitions, c.setState(Open))
+			return
+		}
+		c.successes++
+		if c.successes >= c.settings.halfOpenRequests {
+			transitions = append(transitions, c.setState(Closed))
+		}
+	}
+}
+
+// release frees a half-open probe slot without counting the request as success or failure
+func (c *circuitBreakerClient) release(generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation == c.generation && c.state == HalfOpen {
+		c.probes--
+	}
+}
+
+func (c *circuitBreakerClient) record(failed bool) {
+	if failed {
+		c.consecutive++
+	} else {
+		c.consecutive = 0
+	}
+
+	if c.filled == len(c.outcomes) {
+		if c.outcomes[c.next] {
+			c.failures--
+		}
+	} else {
+		c.filled++
+	}
+	c.outcomes[c.next] = failed
+	if failed {
+		c.failures++
+	}
+	c.next = (c.next + 1) % len(c.outcomes)
+}
+
+func (c *circuitBreakerClient) shouldTrip() bool {
+	if c.consecutive >= c.settings.consecutiveFailures {
+		return true
+	}
+	if c.filled < c.settings.minRequests {
+		return false
+	}
+	return float64(c.failures)/float64(c.filled) >= c.settings.failureRate
+}
+
+// setState must be called with the mutex held, it returns the transition for notify
+func (c *circuitBreakerClient) setState(state State) [2]State {
+	transition := [2]State{c.state, state}
+
+	c.state = state
+	c.generation++
+	c.probes, c.successes = 0, 0
+	c.consecutive = 0
+	c.next, c.filled, c.failures = 0, 0, 0
+	if state == Open {
+		c.openedAt = c.now()
+	}
+
+	return transition
+}
+
+func (c *circuitBreakerClient) notify(transitions [][2]State) {
+	for _, transition := range transitions {
+		for _, onStateChange := range c.onStateChange {
+			onStateChange(transition[0], transition[1])
+		}
+	}
+}
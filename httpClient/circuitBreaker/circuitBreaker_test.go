@@ -0,0 +1,81 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/mocks"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func TestCircuitBreakerClient_Transitions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+
+	var transitions []string
+	config := util.InfrastructureConfig{
+		CircuitBreakerConsecutiveFailures: 2,
+		CircuitBreakerOpenTimeout:         time.Minute,
+	}
+	client := NewCircuitBreakerClient(inner, errorUtil.NewErrorUtil(), config, func(from State, to State) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+
+	now := time.Now()
+	client.(*circuitBreakerClient).now = func() time.Time { return now }
+
+	gomock.InOrder(
+		inner.EXPECT().Get("https://test.url.com").Return(nil, errors.New("connection refused")),
+		inner.EXPECT().Get("https://test.url.com").Return(&http.Response{StatusCode: 503}, nil),
+		inner.EXPECT().Get("https://test.url.com").Return(&http.Response{StatusCode: 200}, nil),
+	)
+
+	// Two consecutive failures trip the breaker
+	_, err := client.Get("https://test.url.com")
+	assert.ErrorContains(t, err, "connection refused")
+	_, err = client.Get("https://test.url.com")
+	assert.NilError(t, err)
+
+	// While open, the wrapped client is not called at all
+	_, err = client.Get("https://test.url.com")
+	var openErr *OpenError
+	assert.Assert(t, errors.As(err, &openErr))
+	assert.Equal(t, now.Add(time.Minute), openErr.RetryAt)
+
+	// After the timeout a successful probe closes it again
+	now = now.Add(time.Minute)
+	resp, err := client.Get("https://test.url.com")
+	assert.NilError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.DeepEqual(t, []string{"closed->open", "open->half-open", "half-open->closed"}, transitions)
+}
+
+func TestCircuitBreakerClient_FailureRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+
+	config := util.InfrastructureConfig{
+		CircuitBreakerFailureRate:         0.5,
+		CircuitBreakerConsecutiveFailures: 100,
+		CircuitBreakerWindowSize:          4,
+		CircuitBreakerMinRequests:         4,
+	}
+	client := NewCircuitBreakerClient(inner, errorUtil.NewErrorUtil(), config)
+
+	statusCodes := []int{200, 500, 200, 500}
+	for _, statusCode := range statusCodes {
+		inner.EXPECT().Get("https://test.url.com").Return(&http.Response{StatusCode: statusCode}, nil)
+		_, err := client.Get("https://test.url.com")
+		assert.NilError(t, err)
+	}
+
+	_, err := client.Get("https://test.url.com")
+	var openErr *OpenError
+	assert.Assert(t, errors.As(err, &openErr))
+}
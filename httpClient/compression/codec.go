@@ -0,0 +1,95 @@
+package compression
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding lists every encoding a response may be sent with, in order of preference
+const acceptEncoding = "zstd, br, gzip, deflate"
+
+// newEncoder compresses what is written to it into w, closing it flushes the remaining data
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported request encoding %q", encoding)
+}
+
+func canDecode(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// newDecoder returns a reader decompressing r, and a closer releasing its resources if it holds any.
+// Decoders that would allocate buffers up front, like zstd for its window, are kept within limit.
+func newDecoder(encoding string, r io.Reader, limit int64) (io.Reader, io.Closer, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(r)
+		return reader, reader, err
+	case "deflate":
+		return newDeflateReader(r)
+	case "br":
+		return brotli.NewReader(r), nil, nil
+	case "zstd":
+		window := uint64(limit)
+		if window < zstd.MinWindowSize {
+			window = zstd.MinWindowSize
+		}
+		// A single decoder goroutine is plenty for a response body, the default starts one per CPU
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(limit)), zstd.WithDecoderMaxWindow(window))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decoder, closerFunc(decoder.Close), nil
+	}
+	return nil, nil, fmt.Errorf("unsupported response encoding %q", encoding)
+}
+
+// exceedsLimit reports whether a decoder refused to go on as the stream would need more than its limit
+func exceedsLimit(err error) bool {
+	return errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded)
+}
+
+// newDeflateReader handles both the zlib stream the RFC asks for and the raw deflate some servers send instead
+func newDeflateReader(r io.Reader) (io.Reader, io.Closer, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		reader, err := zlib.NewReader(buffered)
+		if err != nil {
+			return nil, nil, err
+		}
+		return reader, reader, nil
+	}
+
+	reader := flate.NewReader(buffered)
+	return reader, reader, nil
+}
+
+type closerFunc func()
+
+func (f closerFunc) Close() error {
+	f()
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package compression compresses request bodies of an HttpClient and decompresses the responses it negotiates.
+package compression
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// ExpansionError is returned while reading a response body that decompresses to more than the allowed size,
+// which is what a decompression bomb does.
+type ExpansionError struct {
+	Encoding string
+	Limit    int64
+}
+
+func (e *ExpansionError) Error() string {
+	return fmt.Sprintf("%s encoded response body decompresses to more than %d bytes", e.Encoding, e.Limit)
+}
+
+type compressionClient struct {
+	client    httpclient.HttpClient
+	errorUtil errorHelper.Helper
+	// encoding is the one used for request bodies, empty if they are sent as they are
+	encoding string
+	minBytes int64
+	maxBytes int64
+}
+
+// NewCompressionClient returns an HttpClient that compresses request bodies with HttpCompressRequests, "gzip" or
+// "zstd", once they are at least HttpCompressMinBytes long. Bodies of unknown length are always compressed,
+// bodies that already have a Content-Encoding never.
+//
+// Responses are negotiated with every supported encoding, gzip, deflate, br and zstd, and decompressed while
+// being read, failing with an ExpansionError past HttpDecompressMaxBytes. Requests that set their own
+// Accept-Encoding get the response as it was sent.
+func NewCompressionClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig) (httpclient.HttpClient, error) {
+	c := &compressionClient{
+		client:    client,
+		errorUtil: errorUtil,
+		encoding:  strings.ToLower(strings.TrimSpace(config.HttpCompressRequests)),
+		minBytes:  int64(config.HttpCompressMinBytes),
+		maxBytes:  config.HttpDecompressMaxBytes,
+	}
+	if c.encoding != "" {
+		if _, err := newEncoder(c.encoding, ioutil.Discard); err != nil {
+			return nil, err
+		}
+	}
+	if c.minBytes <= 0 {
+		c.minBytes = 1024
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = 64 << 20
+	}
+	return c, nil
+}
+
+func (c *compressionClient) Get(url string) (*http.Response, error) {
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *compressionClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.PostWithContext(context.Background(), url, contentType, body)
+}
+
+func (c *compressionClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).WithContext(ctx).Build()
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+func (c *compressionClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	builder := httpclient.NewRequestBuilder(http.MethodPost, url).WithContext(ctx).Header("Content-Type", contentType)
+
+	if c.encoding != "" && int64(len(body)) >= c.minBytes {
+		var compressed bytes.Buffer
+		encoder, err := newEncoder(c.encoding, &compressed)
+		if err != nil {
+			return nil, err
+		}
+		encoder.Write(body)
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		builder.Header("Content-Encoding", c.encoding)
+		body = compressed.Bytes()
+	}
+
+	req, err := builder.Body(bytes.NewReader(body)).Build()
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+func (c *compressionClient) Do(req *http.Request) (*http.Response, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	// A length of 0 with a body means it is unknown
+	largeEnough := req.ContentLength <= 0 || req.ContentLength >= c.minBytes
+	if c.encoding == "" || !hasBody || !largeEnough || req.Header.Get("Content-Encoding") != "" {
+		return c.send(req)
+	}
+
+	compressed := req.Clone(req.Context())
+	compressed.Header.Set("Content-Encoding", c.encoding)
+	compressed.Body = c.compress(req.Body)
+	compressed.ContentLength = -1
+	if getBody := req.GetBody; getBody != nil {
+		compressed.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return c.compress(body), nil
+		}
+	}
+	return c.send(compressed)
+}
+
+func (c *compressionClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+// compress streams body through the encoder, so it never has to be held in memory as a whole
+func (c *compressionClient) compress(body io.ReadCloser) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+
+		encoder, err := newEncoder(c.encoding, writer)
+		if err == nil {
+			_, err = io.Copy(encoder, body)
+			if closeErr := encoder.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+func (c *compressionClient) send(req *http.Request) (*http.Response, error) {
+	if _, raw := req.Header["Accept-Encoding"]; raw {
+		return c.client.Do(req)
+	}
+
+	negotiated := req.Clone(req.Context())
+	negotiated.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := c.client.Do(negotiated)
+	if err != nil || resp == nil || resp.Body == nil || req.Method == http.MethodHead {
+		return resp, err
+	}
+
+	c.decode(resp)
+	return resp, nil
+}
+
+// decode replaces the body of resp with one decompressing it, if all its encodings are supported
+func (c *compressionClient) decode(resp *http.Response) {
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return
+	}
+
+	var encodings []string
+	for _, value := range resp.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding == "" || encoding == "identity" {
+				continue
+			}
+			if !canDecode(encoding) {
+				return
+			}
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) == 0 {
+		return
+	}
+
+	resp.Body = &decodingBody{
+		raw:       resp.Body,
+		encodings: encodings,
+		limit:     c.maxBytes,
+		errorUtil: c.errorUtil,
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingBody sets up its decoders on the first read, so an empty or failed body only fails once it is read
+type decodingBody struct {
+	raw       io.ReadCloser
+	encodings []string
+	limit     int64
+	errorUtil errorHelper.Helper
+
+	reader  io.Reader
+	closers []io.Closer
+	read    int64
+	err     error
+}
+
+func (b *decodingBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.reader == nil {
+		if b.err = b.open(); b.err != nil {
+			return 0, b.err
+		}
+	}
+
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit || exceedsLimit(err) {
+		if b.read > b.limit {
+			n -= int(b.read - b.limit)
+			b.read = b.limit
+		}
+		b.err = b.errorUtil.WithStack(&ExpansionError{Encoding: strings.Join(b.encodings, ", "), Limit: b.limit})
+		return n, b.err
+	}
+	return n, err
+}
+
+// open stacks the decoders, the last encoding listed was applied last and is undone first
+func (b *decodingBody) open() error {
+	var reader io.Reader = b.raw
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		decoder, closer, err := newDecoder(b.encodings[i], reader, b.limit)
+		if err != nil {
+			return err
+		}
+		if closer != nil {
+			b.closers = append(b.closers, closer)
+		}
+		reader = decoder
+	}
+	b.reader = reader
+	return nil
+}
+
+func (b *decodingBody) Close() error {
+	for _, closer := range b.closers {
+		closer.Close()
+	}
+	return b.raw.Close()
+}
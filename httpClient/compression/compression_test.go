@@ -0,0 +1,247 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"gotest.tools/assert"
+)
+
+var payload = strings.Repeat(`{"key":"value"},`, 200)
+
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buffer)
+	case "deflate":
+		writer = zlib.NewWriter(&buffer)
+	case "raw-deflate":
+		writer, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buffer)
+	case "zstd":
+		encoder, err := zstd.NewWriter(&buffer)
+		assert.NilError(t, err)
+		writer = encoder
+	}
+	_, err := writer.Write(data)
+	assert.NilError(t, err)
+	assert.NilError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func decode(t *testing.T, encoding string, data []byte) []byte {
+	reader, closer, err := newDecoder(encoding, bytes.NewReader(data), 64<<20)
+	assert.NilError(t, err)
+	if closer != nil {
+		defer closer.Close()
+	}
+	decoded, err := ioutil.ReadAll(reader)
+	assert.NilError(t, err)
+	return decoded
+}
+
+// echoBackend answers with the request body it received, decompressed, and records its headers
+func echoBackend(t *testing.T, headers *http.Header) *fake.Client {
+	inner := fake.NewFakeClient()
+	inner.On("*", "https://test.url.com*").Handle(func(req *http.Request) (*http.Response, error) {
+		*headers = req.Header.Clone()
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+		}
+		if encoding := req.Header.Get("Content-Encoding"); encoding != "" {
+			body = decode(t, encoding, body)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+	})
+	return inner
+}
+
+func TestCompressionClient_Requests(t *testing.T) {
+	tests := []struct {
+		name         string
+		encoding     string
+		body         string
+		wantEncoding string
+	}{
+		{
+			name:         "Successful--Gzip",
+			encoding:     "gzip",
+			body:         payload,
+			wantEncoding: "gzip",
+		},
+		{
+			name:         "Successful--Zstd",
+			encoding:     "zstd",
+			body:         payload,
+			wantEncoding: "zstd",
+		},
+		{
+			name:         "Successful--BelowThreshold",
+			encoding:     "gzip",
+			body:         `{"key":"value"}`,
+			wantEncoding: "",
+		},
+		{
+			name:         "Successful--Disabled",
+			encoding:     "",
+			body:         payload,
+			wantEncoding: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers http.Header
+			client, err := NewCompressionClient(echoBackend(t, &headers), errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpCompressRequests: tt.encoding})
+			assert.NilError(t, err)
+
+			resp, err := client.Post("https://test.url.com", "application/json", []byte(tt.body))
+			assert.NilError(t, err)
+			echoed, _ := ioutil.ReadAll(resp.Body)
+
+			assert.Equal(t, tt.wantEncoding, headers.Get("Content-Encoding"))
+			assert.Equal(t, "application/json", headers.Get("Content-Type"))
+			assert.Equal(t, tt.body, string(echoed))
+		})
+	}
+}
+
+func TestCompressionClient_StreamedRequest(t *testing.T) {
+	var headers http.Header
+	client, err := NewCompressionClient(echoBackend(t, &headers), errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpCompressRequests: "zstd"})
+	assert.NilError(t, err)
+
+	// A body of unknown length is compressed whatever its size
+	req, err := http.NewRequest(http.MethodPut, "https://test.url.com", ioutil.NopCloser(strings.NewReader("short")))
+	assert.NilError(t, err)
+	resp, err := client.Do(req)
+	assert.NilError(t, err)
+	echoed, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "zstd", headers.Get("Content-Encoding"))
+	assert.Equal(t, "short", string(echoed))
+}
+
+func TestCompressionClient_Responses(t *testing.T) {
+	tests := []struct {
+		name      string
+		encodings []string
+		header    string
+	}{
+		{name: "Successful--Gzip", encodings: []string{"gzip"}, header: "gzip"},
+		{name: "Successful--Deflate", encodings: []string{"deflate"}, header: "deflate"},
+		{name: "Successful--RawDeflate", encodings: []string{"raw-deflate"}, header: "deflate"},
+		{name: "Successful--Brotli", encodings: []string{"br"}, header: "br"},
+		{name: "Successful--Zstd", encodings: []string{"zstd"}, header: "zstd"},
+		{name: "Successful--Stacked", encodings: []string{"gzip", "br"}, header: "gzip, br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(payload)
+			for _, encoding := range tt.encodings {
+				body = encode(t, encoding, body)
+			}
+
+			var accepted string
+			inner := fake.NewFakeClient()
+			inner.On(http.MethodGet, "https://test.url.com").Handle(func(req *http.Request) (*http.Response, error) {
+				accepted = req.Header.Get("Accept-Encoding")
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{"Content-Encoding": {tt.header}},
+					Body:          ioutil.NopCloser(bytes.NewReader(body)),
+					ContentLength: int64(len(body)),
+				}, nil
+			})
+			client, err := NewCompressionClient(inner, errorUtil.NewErrorUtil(), util.InfrastructureConfig{})
+			assert.NilError(t, err)
+
+			resp, err := client.Get("https://test.url.com")
+			assert.NilError(t, err)
+			decoded, err := ioutil.ReadAll(resp.Body)
+			assert.NilError(t, err)
+			assert.NilError(t, resp.Body.Close())
+
+			assert.Equal(t, acceptEncoding, accepted)
+			assert.Equal(t, payload, string(decoded))
+			assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, int64(-1), resp.ContentLength)
+		})
+	}
+}
+
+func TestCompressionClient_DecompressionBomb(t *testing.T) {
+	// A zstd frame asking for a larger window than the limit is refused right away, one with a small window is
+	// cut off like gzip once it expands past the limit
+	var smallWindow bytes.Buffer
+	encoder, err := zstd.NewWriter(&smallWindow, zstd.WithWindowSize(zstd.MinWindowSize))
+	assert.NilError(t, err)
+	encoder.Write(make([]byte, 1<<20))
+	assert.NilError(t, encoder.Close())
+
+	for name, bomb := range map[string][]byte{
+		"gzip":              encode(t, "gzip", make([]byte, 1<<20)),
+		"zstd":              encode(t, "zstd", make([]byte, 1<<20)),
+		"zstd-small-window": smallWindow.Bytes(),
+	} {
+		encoding := strings.TrimSuffix(name, "-small-window")
+		bomb := bomb
+		t.Run(name, func(t *testing.T) {
+			inner := fake.NewFakeClient()
+			inner.On(http.MethodGet, "https://test.url.com").Respond(http.StatusOK, string(bomb)).Header("Content-Encoding", encoding)
+			client, err := NewCompressionClient(inner, errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpDecompressMaxBytes: 4096})
+			assert.NilError(t, err)
+
+			resp, err := client.Get("https://test.url.com")
+			assert.NilError(t, err)
+			decoded, err := ioutil.ReadAll(resp.Body)
+			assert.NilError(t, resp.Body.Close())
+
+			var expansion *ExpansionError
+			assert.Assert(t, errors.As(err, &expansion), err)
+			assert.Equal(t, int64(4096), expansion.Limit)
+			assert.Assert(t, len(decoded) <= 4096)
+		})
+	}
+}
+
+func TestCompressionClient_OwnAcceptEncoding(t *testing.T) {
+	compressed := encode(t, "gzip", []byte(payload))
+	inner := fake.NewFakeClient()
+	inner.On(http.MethodGet, "https://test.url.com").Respond(http.StatusOK, string(compressed)).Header("Content-Encoding", "gzip")
+	client, err := NewCompressionClient(inner, errorUtil.NewErrorUtil(), util.InfrastructureConfig{})
+	assert.NilError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://test.url.com", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	assert.NilError(t, err)
+	raw, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.DeepEqual(t, compressed, raw)
+}
+
+func TestNewCompressionClient_InvalidConfig(t *testing.T) {
+	_, err := NewCompressionClient(nil, errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpCompressRequests: "br"})
+	assert.Error(t, err, `unsupported request encoding "br"`)
+}
@@ -0,0 +1,285 @@
+// Package fake provides an in-memory HttpClient for tests, answering requests from a table of routes.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// HandlerFunc computes the answer to a request dynamically.
+type HandlerFunc func(req *http.Request) (*http.Response, error)
+
+// Call is a request received by the fake, with its body already read.
+type Call struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// NoRouteError is returned for requests no route matches.
+type NoRouteError struct {
+	Method string
+	URL    string
+}
+
+func (e *NoRouteError) Error() string {
+	return fmt.Sprintf("no route for %s %s", e.Method, e.URL)
+}
+
+// Route is the answer for requests matching a method and URL pattern. Configure it with its methods,
+// the last of Respond, Fail and Handle wins.
+type Route struct {
+	method  string
+	pattern *regexp.Regexp
+	query   bool
+
+	mutex      sync.Mutex
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+	handler    HandlerFunc
+	latency    time.Duration
+}
+
+// Respond answers with statusCode and body.
+func (r *Route) Respond(statusCode int, body string) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.statusCode, r.body, r.err, r.handler = statusCode, []byte(body), nil, nil
+	return r
+}
+
+// Header adds a header to the response given with Respond.
+func (r *Route) Header(key string, value string) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.header.Add(key, value)
+	return r
+}
+
+// Fail answers with err instead of a response.
+func (r *Route) Fail(err error) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.err, r.handler = err, nil
+	return r
+}
+
+// Handle lets handler answer.
+func (r *Route) Handle(handler HandlerFunc) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handler, r.err = handler, nil
+	return r
+}
+
+// Latency delays the answer, a cancelled context ends the wait early with its error.
+func (r *Route) Latency(latency time.Duration) *Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.latency = latency
+	return r
+}
+
+func (r *Route) matches(req *http.Request) bool {
+	if r.method != "" && r.method != "*" && !strings.EqualFold(r.method, req.Method) {
+		return false
+	}
+
+	target := *req.URL
+	if !r.query {
+		target.RawQuery = ""
+	}
+	target.Fragment = ""
+	return r.pattern.MatchString(target.String())
+}
+
+func (r *Route) answer(req *http.Request) (*http.Response, error) {
+	r.mutex.Lock()
+	statusCode, header, body, err, handler, latency := r.statusCode, r.header.Clone(), r.body, r.err, r.handler, r.latency
+	r.mutex.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if handler != nil {
+		return handler(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Client is an HttpClient answering from its routes. It is safe for concurrent use,
+// routes can even be added while requests are running.
+type Client struct {
+	mutex  sync.Mutex
+	routes []*Route
+	calls  []Call
+	closed bool
+}
+
+func NewFakeClient() *Client {
+	return &Client{}
+}
+
+// On adds a route for method, "" or "*" for any, and urlPattern. In the pattern "*" matches any sequence of
+// characters, the query is only compared if the pattern has one. Later routes take precedence over earlier ones.
+// A route answers 200 with an empty body until configured otherwise.
+func (c *Client) On(method string, urlPattern string) *Route {
+	parts := strings.Split(urlPattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	route := &Route{
+		method:     method,
+		pattern:    regexp.MustCompile("^" + strings.Join(parts, ".*") + "$"),
+		query:      strings.Contains(urlPattern, "?"),
+		statusCode: http.StatusOK,
+		header:     http.Header{},
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.routes = append(c.routes, route)
+	return route
+}
+
+// Calls returns every request received so far, in order.
+func (c *Client) Calls() []Call {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]Call(nil), c.calls...)
+}
+
+// CallsTo returns the received requests with the given method and exact URL.
+func (c *Client) CallsTo(method string, url string) []Call {
+	var calls []Call
+	for _, call := range c.Calls() {
+		if call.Method == method && call.URL == url {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets all routes and calls.
+func (c *Client) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.routes, c.calls, c.closed = nil, nil, false
+}
+
+func (c *Client) Get(url string) (*http.Response, error) {
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *Client) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.PostWithContext(context.Background(), url, contentType, body)
+}
+
+func (c *Client) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).WithContext(ctx).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *Client) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodPost, url).
+		WithContext(ctx).
+		Header("Content-Type", contentType).
+		Body(bytes.NewReader(body)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Handlers get to read the body as well
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, httpclient.ErrShutdown
+	}
+	c.calls = append(c.calls, Call{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body})
+
+	var route *Route
+	for i := len(c.routes) - 1; i >= 0; i-- {
+		if c.routes[i].matches(req) {
+			route = c.routes[i]
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	if route == nil {
+		return nil, &NoRouteError{Method: req.Method, URL: req.URL.String()}
+	}
+	return route.answer(req)
+}
+
+// Shutdown makes every further request fail with httpclient.ErrShutdown.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	return nil
+}
@@ -0,0 +1,87 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"gotest.tools/assert"
+)
+
+func TestClient_Routes(t *testing.T) {
+	client := NewFakeClient()
+	client.On("*", "https://test.url.com/*").Respond(http.StatusNotFound, "")
+	client.On(http.MethodGet, "https://test.url.com/items/*").Respond(http.StatusOK, `{"id":1}`).Header("Content-Type", "application/json")
+	client.On(http.MethodPost, "https://test.url.com/items").Fail(errors.New("connection reset"))
+	client.On(http.MethodPut, "https://test.url.com/items/*").Handle(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: ioutil.NopCloser(req.Body), Header: http.Header{"Echo": {string(body)}}}, nil
+	})
+	client.On(http.MethodGet, "https://test.url.com/slow").Latency(time.Second)
+
+	resp, err := client.Get("https://test.url.com/items/1?verbose=true")
+	assert.NilError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, `{"id":1}`, string(body))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	resp, err = client.Get("https://test.url.com/other")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = client.Post("https://test.url.com/items", "application/json", []byte(`{}`))
+	assert.ErrorContains(t, err, "connection reset")
+
+	req, _ := http.NewRequest(http.MethodPut, "https://test.url.com/items/1", nil)
+	req.Body = ioutil.NopCloser(strings.NewReader("payload"))
+	resp, err = client.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, "payload", resp.Header.Get("Echo"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.GetWithContext(ctx, "https://test.url.com/slow")
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+
+	_, err = client.Get("https://other.url.com")
+	var noRoute *NoRouteError
+	assert.Assert(t, errors.As(err, &noRoute))
+
+	assert.Equal(t, 6, len(client.Calls()))
+	posts := client.CallsTo(http.MethodPost, "https://test.url.com/items")
+	assert.Equal(t, 1, len(posts))
+	assert.Equal(t, `{}`, string(posts[0].Body))
+	assert.Equal(t, "application/json", posts[0].Header.Get("Content-Type"))
+
+	assert.NilError(t, client.Shutdown(context.Background()))
+	_, err = client.Get("https://test.url.com/items/1")
+	assert.Assert(t, errors.Is(err, httpclient.ErrShutdown))
+}
+
+func TestClient_Concurrent(t *testing.T) {
+	client := NewFakeClient()
+	client.On(http.MethodGet, "https://test.url.com/*").Respond(http.StatusOK, "ok")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client.On(http.MethodGet, fmt.Sprintf("https://test.url.com/%d", i)).Respond(http.StatusOK, "ok")
+			resp, err := client.Get(fmt.Sprintf("https://test.url.com/%d", i))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, len(client.Calls()))
+}
@@ -0,0 +1,271 @@
+// Package faults wraps an HttpClient to inject network failures for chaos testing.
+package faults
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// Faults holds the probability, between 0 and 1, of every kind of fault. They are rolled for independently
+// in the order of the fields, the first error or status fault ends the request.
+type Faults struct {
+	// Latency is added before the request is sent
+	LatencyProbability float64
+	Latency            time.Duration
+	// ResetProbability fails the request with a connection reset
+	ResetProbability float64
+	// TimeoutProbability lets the request hang until its context is done, or fails it right away without a deadline
+	TimeoutProbability float64
+	// StatusProbability answers with one of StatusCodes without sending the request
+	StatusProbability float64
+	StatusCodes       []int
+	// TruncateProbability cuts the response body off somewhere, reading it ends with io.ErrUnexpectedEOF
+	TruncateProbability float64
+	// CorruptProbability flips random bytes of the response body
+	CorruptProbability float64
+}
+
+// Rule applies Faults to the requests matching Method, "" or "*" for any, and URLPattern,
+// in which "*" matches any sequence of characters.
+type Rule struct {
+	Method     string
+	URLPattern string
+	Faults     Faults
+}
+
+// InjectedError is returned for faults that end a request with an error.
+type InjectedError struct {
+	// Fault is "reset" or "timeout"
+	Fault string
+	Err   error
+}
+
+func (e *InjectedError) Error() string {
+	return fmt.Sprintf("injected %s: %v", e.Fault, e.Err)
+}
+
+func (e *InjectedError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout lets injected timeouts pass for net.Error timeouts.
+func (e *InjectedError) Timeout() bool {
+	return e.Fault == "timeout"
+}
+
+func (e *InjectedError) Temporary() bool {
+	return true
+}
+
+type rule struct {
+	method  string
+	pattern *regexp.Regexp
+	faults  Faults
+}
+
+type faultClient struct {
+	client httpclient.HttpClient
+	rules  []rule
+
+	// random is seeded, so the same sequence of requests gets the same faults
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// NewFaultClient returns an HttpClient that injects the faults of the first rule matching a request.
+// Requests matching no rule are passed through unchanged.
+func NewFaultClient(client httpclient.HttpClient, seed int64, rules ...Rule) httpclient.HttpClient {
+	c := &faultClient{
+		client: client,
+		random: rand.New(rand.NewSource(seed)),
+	}
+
+	for _, r := range rules {
+		parts := strings.Split(r.URLPattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		c.rules = append(c.rules, rule{
+			method:  r.Method,
+			pattern: regexp.MustCompile("^" + strings.Join(parts, ".*") + "$"),
+			faults:  r.Faults,
+		})
+	}
+
+	return c
+}
+
+func (c *faultClient) Get(url string) (*http.Response, error) {
+	return c.call(context.Background(), http.MethodGet, url, func() (*http.Response, error) {
+		return c.client.Get(url)
+	})
+}
+
+func (c *faultClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(context.Background(), http.MethodPost, url, func() (*http.Response, error) {
+		return c.client.Post(url, contentType, body)
+	})
+}
+
+func (c *faultClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.call(ctx, http.MethodGet, url, func() (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *faultClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(ctx, http.MethodPost, url, func() (*http.Response, error) {
+		return c.client.PostWithContext(ctx, url, contentType, body)
+	})
+}
+
+func (c *faultClient) Do(req *http.Request) (*http.Response, error) {
+	return c.call(req.Context(), req.Method, req.URL.String(), func() (*http.Response, error) {
+		return c.client.Do(req)
+	})
+}
+
+func (c *faultClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *faultClient) call(ctx context.Context, method string, url string, send func() (*http.Response, error)) (*http.Response, error) {
+	faults, ok := c.match(method, url)
+	if !ok {
+		return send()
+	}
+
+	if c.roll(faults.LatencyProbability) {
+		timer := time.NewTimer(faults.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	if c.roll(faults.ResetProbability) {
+		return nil, &InjectedError{Fault: "reset", Err: syscall.ECONNRESET}
+	}
+
+	if c.roll(faults.TimeoutProbability) {
+		if _, hasDeadline := ctx.Deadline(); hasDeadline {
+			<-ctx.Done()
+		}
+		return nil, &InjectedError{Fault: "timeout", Err: context.DeadlineExceeded}
+	}
+
+	if len(faults.StatusCodes) > 0 && c.roll(faults.StatusProbability) {
+		statusCode := faults.StatusCodes[c.intn(len(faults.StatusCodes))]
+		return &http.Response{
+			Status:     strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+			StatusCode: statusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+		}, nil
+	}
+
+	resp, err := send()
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+
+	truncate := c.roll(faults.TruncateProbability)
+	corrupt := c.roll(faults.CorruptProbability)
+	if !truncate && !corrupt {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if corrupt {
+		c.corrupt(body)
+	}
+	if truncate && len(body) > 0 {
+		resp.Body = &truncatedBody{Reader: bytes.NewReader(body[:c.intn(len(body))])}
+	} else {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+func (c *faultClient) match(method string, url string) (Faults, bool) {
+	for _, r := range c.rules {
+		if r.method != "" && r.method != "*" && !strings.EqualFold(r.method, method) {
+			continue
+		}
+		if r.pattern.MatchString(url) {
+			return r.faults, true
+		}
+	}
+	return Faults{}, false
+}
+
+func (c *faultClient) roll(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.random.Float64() < probability
+}
+
+func (c *faultClient) intn(n int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.random.Intn(n)
+}
+
+// corrupt flips about one in a hundred bytes, at least one
+func (c *faultClient) corrupt(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i := 0; i <= len(body)/100; i++ {
+		position := c.random.Intn(len(body))
+		body[position] ^= byte(1 + c.random.Intn(255))
+	}
+}
+
+// truncatedBody ends with io.ErrUnexpectedEOF instead of io.EOF, like a connection dropped mid-body
+type truncatedBody struct {
+	io.Reader
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	"gotest.tools/assert"
+)
+
+func newBackend() *fake.Client {
+	backend := fake.NewFakeClient()
+	backend.On("*", "https://test.url.com/*").Respond(http.StatusOK, `{"key":"value","other":"value"}`)
+	return backend
+}
+
+func TestFaultClient_Faults(t *testing.T) {
+	tests := []struct {
+		name       string
+		faults     Faults
+		wantErr    error
+		wantStatus int
+		wantRead   error
+	}{
+		{
+			name:    "Reset",
+			faults:  Faults{ResetProbability: 1},
+			wantErr: syscall.ECONNRESET,
+		},
+		{
+			name:    "Timeout",
+			faults:  Faults{TimeoutProbability: 1},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:       "Status",
+			faults:     Faults{StatusProbability: 1, StatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "Truncate",
+			faults:     Faults{TruncateProbability: 1},
+			wantStatus: http.StatusOK,
+			wantRead:   io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newBackend()
+			client := NewFaultClient(backend, 1, Rule{URLPattern: "https://test.url.com/*", Faults: tt.faults})
+
+			resp, err := client.Get("https://test.url.com/items")
+			if tt.wantErr != nil {
+				assert.Assert(t, errors.Is(err, tt.wantErr), err)
+				var netErr net.Error
+				assert.Assert(t, errors.As(err, &netErr))
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			_, err = ioutil.ReadAll(resp.Body)
+			assert.Equal(t, tt.wantRead, err)
+		})
+	}
+}
+
+func TestFaultClient_LatencyAndCorruption(t *testing.T) {
+	client := NewFaultClient(newBackend(), 1, Rule{
+		Method:     http.MethodGet,
+		URLPattern: "https://test.url.com/*",
+		Faults:     Faults{LatencyProbability: 1, Latency: 20 * time.Millisecond, CorruptProbability: 1},
+	})
+
+	start := time.Now()
+	resp, err := client.Get("https://test.url.com/items")
+	assert.NilError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Assert(t, time.Since(start) >= 20*time.Millisecond)
+	assert.Assert(t, string(body) != `{"key":"value","other":"value"}`)
+}
+
+func TestFaultClient_PerRouteAndSeeded(t *testing.T) {
+	outcomes := func(seed int64) []bool {
+		client := NewFaultClient(newBackend(), seed, Rule{
+			Method:     http.MethodPost,
+			URLPattern: "https://test.url.com/flaky",
+			Faults:     Faults{ResetProbability: 0.5},
+		})
+
+		// Other routes are never affected
+		for i := 0; i < 10; i++ {
+			_, err := client.Post("https://test.url.com/stable", "application/json", nil)
+			assert.NilError(t, err)
+		}
+
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, err := client.Post("https://test.url.com/flaky", "application/json", nil)
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first := outcomes(42)
+	assert.DeepEqual(t, first, outcomes(42))
+	assert.Assert(t, contains(first, true) && contains(first, false))
+}
+
+func contains(values []bool, value bool) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+// Package hedge cuts the tail latency of an HttpClient by sending a duplicate of a slow request to race the original.
+package hedge
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// Stats counts how much hedging was done and how often it paid off.
+type Stats struct {
+	// Requests that were eligible for hedging
+	Requests uint64
+	// Hedges is the number of duplicates sent
+	Hedges uint64
+	// Won counts the requests answered by a duplicate before the original came back
+	Won uint64
+}
+
+// Client is an HttpClient that also reports how well hedging works.
+type Client interface {
+	httpclient.HttpClient
+	Stats() Stats
+}
+
+const (
+	// latencyWindow is the number of latest latencies the percentile is taken from
+	latencyWindow = 100
+	// minSamples are needed before the percentile replaces the fixed delay
+	minSamples = 10
+)
+
+type hedgeClient struct {
+	client     httpclient.HttpClient
+	delay      time.Duration
+	percentile float64
+	maxHedges  int
+
+	mutex     sync.Mutex
+	latencies []time.Duration
+	next      int
+
+	requests uint64
+	hedges   uint64
+	won      uint64
+}
+
+// NewHedgeClient returns a Client that sends another copy of an idempotent request whenever the hedge delay
+// passed without a response, up to HedgeMaxHedges copies. The first successful response, one without transport
+// error or 5xx status, is returned and the other copies are cancelled.
+//
+// The delay is HedgeDelay, or with HedgePercentile set, e.g. to 0.95, that percentile of the latest observed
+// latencies once there are enough of them. Every copy that got a response counts, not only the winners, though
+// copies cancelled before their response arrived can't, which still leans the percentile a little low.
+//
+// Posts are never hedged, neither are requests sent with Do whose method isn't idempotent, unless they carry an
+// Idempotency-Key header.
+func NewHedgeClient(client httpclient.HttpClient, config util.InfrastructureConfig) Client {
+	c := &hedgeClient{
+		client:     client,
+		delay:      config.HedgeDelay,
+		percentile: config.HedgePercentile,
+		maxHedges:  config.HedgeMaxHedges,
+		latencies:  make([]time.Duration, 0, latencyWindow),
+	}
+	if c.delay <= 0 {
+		c.delay = 100 * time.Millisecond
+	}
+	if c.percentile < 0 || c.percentile >= 1 {
+		c.percentile = 0
+	}
+	if c.maxHedges <= 0 {
+		c.maxHedges = 1
+	}
+	return c
+}
+
+func (c *hedgeClient) Get(url string) (*http.Response, error) {
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *hedgeClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.client.Post(url, contentType, body)
+}
+
+func (c *hedgeClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.hedge(ctx, func(ctx context.Context) (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *hedgeClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.client.PostWithContext(ctx, url, contentType, body)
+}
+
+func (c *hedgeClient) Do(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if !replayable || !httpclient.IsIdempotent(req) {
+		return c.client.Do(req)
+	}
+
+	return c.hedge(req.Context(), func(ctx context.Context) (*http.Response, error) {
+		copied := req.Clone(ctx)
+		// Every copy reads its own body, the original one can't be shared between them
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			copied.Body = body
+		}
+		return c.client.Do(copied)
+	})
+}
+
+func (c *hedgeClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *hedgeClient) Stats() Stats {
+	return Stats{
+		Requests: atomic.LoadUint64(&c.requests),
+		Hedges:   atomic.LoadUint64(&c.hedges),
+		Won:      atomic.LoadUint64(&c.won),
+	}
+}
+
+type result struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+func (c *hedgeClient) hedge(ctx context.Context, send func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	atomic.AddUint64(&c.requests, 1)
+
+	// Buffered for every copy, so the ones finishing after the winner never block
+	results := make(chan result, c.maxHedges+1)
+	var cancels []context.CancelFunc
+	start := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			started := time.Now()
+			resp, err := send(attemptCtx)
+			if err == nil {
+				c.observe(time.Since(started))
+			}
+			results <- result{index: index, resp: resp, err: err}
+		}()
+	}
+
+	start()
+	pending := 1
+	delay := c.hedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var failed *result
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) <= c.maxHedges {
+				atomic.AddUint64(&c.hedges, 1)
+				start()
+				pending++
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil && r.resp.StatusCode < http.StatusInternalServerError {
+				if r.index > 0 {
+					atomic.AddUint64(&c.won, 1)
+				}
+				if failed != nil {
+					discard(*failed)
+				}
+				return c.keep(r, cancels, results, pending)
+			}
+
+			if failed != nil {
+				discard(*failed)
+			}
+			failed = &r
+			// Only give up once no copy is left that could still succeed
+			if pending == 0 {
+				return c.keep(r, cancels, results, pending)
+			}
+		}
+	}
+}
+
+// keep cancels every copy but r, whose context lives until its body is closed
+func (c *hedgeClient) keep(r result, cancels []context.CancelFunc, results chan result, pending int) (*http.Response, error) {
+	for i, cancel := range cancels {
+		if i != r.index {
+			cancel()
+		}
+	}
+
+	go func() {
+		for ; pending > 0; pending-- {
+			discard(<-results)
+		}
+	}()
+
+	if r.err != nil || r.resp.Body == nil {
+		cancels[r.index]()
+		return r.resp, r.err
+	}
+	r.resp.Body = httpclient.OnClose(r.resp.Body, cancels[r.index])
+	return r.resp, nil
+}
+
+func (c *hedgeClient) hedgeDelay() time.Duration {
+	if c.percentile == 0 {
+		return c.delay
+	}
+
+	c.mutex.Lock()
+	if len(c.latencies) < minSamples {
+		c.mutex.Unlock()
+		return c.delay
+	}
+	sorted := append([]time.Duration{}, c.latencies...)
+	c.mutex.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(c.percentile*float64(len(sorted)))]
+}
+
+func (c *hedgeClient) observe(latency time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.latencies) < latencyWindow {
+		c.latencies = append(c.latencies, latency)
+		return
+	}
+	c.latencies[c.next] = latency
+	c.next = (c.next + 1) % latencyWindow
+}
+
+func discard(r result) {
+	if r.resp != nil && r.resp.Body != nil {
+		r.resp.Body.Close()
+	}
+}
@@ -0,0 +1,145 @@
+package hedge
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"gotest.tools/assert"
+)
+
+// slowFirst answers the first request after slow, or once it is cancelled, and all others right away
+func slowFirst(slow time.Duration, cancelled chan<- error) fake.HandlerFunc {
+	var calls int32
+	return func(req *http.Request) (*http.Response, error) {
+		call := atomic.AddInt32(&calls, 1)
+		if call == 1 {
+			select {
+			case <-time.After(slow):
+			case <-req.Context().Done():
+				cancelled <- req.Context().Err()
+				return nil, req.Context().Err()
+			}
+		}
+		body := []byte("call " + string(rune('0'+call)))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+	}
+}
+
+func TestHedgeClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		slow       time.Duration
+		wantBody   string
+		wantHedges uint64
+		wantWon    uint64
+	}{
+		{
+			name:       "Successful--HedgeWins",
+			slow:       time.Second,
+			wantBody:   "call 2",
+			wantHedges: 1,
+			wantWon:    1,
+		},
+		{
+			name:       "Successful--OriginalInTime",
+			slow:       0,
+			wantBody:   "call 1",
+			wantHedges: 0,
+			wantWon:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := make(chan error, 1)
+			inner := fake.NewFakeClient()
+			inner.On(http.MethodGet, "https://test.url.com").Handle(slowFirst(tt.slow, cancelled))
+			client := NewHedgeClient(inner, util.InfrastructureConfig{HedgeDelay: 20 * time.Millisecond})
+
+			resp, err := client.Get("https://test.url.com")
+			assert.NilError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantBody, string(body))
+			assert.DeepEqual(t, Stats{Requests: 1, Hedges: tt.wantHedges, Won: tt.wantWon}, client.Stats())
+			if tt.wantWon > 0 {
+				// The slow original is cancelled once the hedge won
+				assert.Equal(t, context.Canceled, <-cancelled)
+			}
+		})
+	}
+}
+
+func TestHedgeClient_MaxHedges(t *testing.T) {
+	inner := fake.NewFakeClient()
+	inner.On(http.MethodGet, "https://test.url.com").Latency(100*time.Millisecond).Respond(http.StatusOK, "")
+	client := NewHedgeClient(inner, util.InfrastructureConfig{HedgeDelay: 10 * time.Millisecond, HedgeMaxHedges: 2})
+
+	resp, err := client.GetWithContext(context.Background(), "https://test.url.com")
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, 3, len(inner.Calls()))
+	assert.Equal(t, uint64(2), client.Stats().Hedges)
+}
+
+func TestHedgeClient_FailuresWaitForOtherCopies(t *testing.T) {
+	var calls int32
+	inner := fake.NewFakeClient()
+	inner.On(http.MethodGet, "https://test.url.com").Handle(func(req *http.Request) (*http.Response, error) {
+		// The original fails after the hedge was sent, which then succeeds
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(30 * time.Millisecond)
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}
+		time.Sleep(30 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	client := NewHedgeClient(inner, util.InfrastructureConfig{HedgeDelay: 10 * time.Millisecond})
+
+	resp, err := client.Get("https://test.url.com")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestHedgeClient_NotIdempotent(t *testing.T) {
+	inner := fake.NewFakeClient()
+	inner.On("*", "https://test.url.com").Latency(50*time.Millisecond).Respond(http.StatusCreated, "")
+	client := NewHedgeClient(inner, util.InfrastructureConfig{HedgeDelay: time.Millisecond})
+
+	_, err := client.Post("https://test.url.com", "application/json", []byte(`{"key":"value"}`))
+	assert.NilError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, "https://test.url.com", bytes.NewReader([]byte(`{"key":"value"}`)))
+	assert.NilError(t, err)
+	_, err = client.Do(req)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 2, len(inner.Calls()))
+	assert.DeepEqual(t, Stats{}, client.Stats())
+}
+
+func TestHedgeClient_PercentileDelay(t *testing.T) {
+	client := NewHedgeClient(nil, util.InfrastructureConfig{HedgeDelay: time.Second, HedgePercentile: 0.9}).(*hedgeClient)
+
+	// The fixed delay applies until there are enough samples
+	for i := 1; i < minSamples; i++ {
+		client.observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, time.Second, client.hedgeDelay())
+
+	for i := minSamples; i <= latencyWindow+10; i++ {
+		client.observe(time.Duration(i) * time.Millisecond)
+	}
+	// The window holds 11ms to 110ms
+	assert.Equal(t, 101*time.Millisecond, client.hedgeDelay())
+}
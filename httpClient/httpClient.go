@@ -1,13 +1,26 @@
 package httpclient
 
 import (
+	"context"
+	"errors"
 	"net/http"
 )
 
+// ErrShutdown is returned for requests made after Shutdown was called.
+var ErrShutdown = errors.New("http client is shut down")
+
 //go:generate mockgen -source ../httpClient/httpClient.go -destination=mocks/mock_httpClient.go -package=mocks
 
 type HttpClient interface {
 	Get(url string) (*http.Response, error)
 	Post(url string, contentType string, body []byte) (*http.Response, error)
-	Shutdown()
+	// GetWithContext behaves like Get but aborts the request once ctx is cancelled or its deadline passes.
+	GetWithContext(ctx context.Context, url string) (*http.Response, error)
+	// PostWithContext behaves like Post but aborts the request once ctx is cancelled or its deadline passes.
+	PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error)
+	// Do sends an arbitrary request, e.g. one assembled with a RequestBuilder.
+	Do(req *http.Request) (*http.Response, error)
+	// Shutdown refuses new requests with ErrShutdown and waits for the ones in flight to finish.
+	// Requests still running when ctx is done are cancelled and ctx.Err() is returned.
+	Shutdown(ctx context.Context) error
 }
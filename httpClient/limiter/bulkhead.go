@@ -0,0 +1,109 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+type compartment struct {
+	slots   chan struct{}
+	waiting int
+}
+
+type bulkhead struct {
+	errorUtil errorHelper.Helper
+	limits    map[string]string
+	queueSize int
+
+	mutex        sync.Mutex
+	compartments map[string]*compartment
+}
+
+// NewBulkheadClient returns an HttpClient that has at most the configured number of requests in flight per host.
+// A request counts as in flight until its response body is closed. Requests over the limit wait for a free slot,
+// unless InFlightQueueSize requests are already waiting for that host, 100 if not set. A negative InFlightQueueSize
+// fails requests over the limit right away.
+func NewBulkheadClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig) (httpclient.HttpClient, error) {
+	limits, err := parseHostEntries(config.MaxInFlightPerHost)
+	if err != nil {
+		return nil, errorUtil.WithStack(err)
+	}
+	for host, limit := range limits {
+		if max, err := strconv.Atoi(limit); err != nil || max < 1 {
+			return nil, errorUtil.WithStack(fmt.Errorf("invalid in-flight limit %q for host %q", limit, host))
+		}
+	}
+
+	bulkhead := &bulkhead{
+		errorUtil:    errorUtil,
+		limits:       limits,
+		queueSize:    queueSize(config.InFlightQueueSize),
+		compartments: map[string]*compartment{},
+	}
+	return &guardedClient{client: client, guard: bulkhead.acquire}, nil
+}
+
+func (b *bulkhead) acquire(ctx context.Context, host string) (func(), error) {
+	b.mutex.Lock()
+	compartment := b.compartment(host)
+	if compartment == nil {
+		b.mutex.Unlock()
+		return func() {}, nil
+	}
+
+	release := func() { <-compartment.slots }
+	select {
+	case compartment.slots <- struct{}{}:
+		b.mutex.Unlock()
+		return release, nil
+	default:
+	}
+
+	if compartment.waiting >= b.queueSize {
+		b.mutex.Unlock()
+		return nil, b.errorUtil.WithStack(&QueueFullError{Limit: "in-flight limit", Host: host})
+	}
+	compartment.waiting++
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		compartment.waiting--
+		b.mutex.Unlock()
+	}()
+
+	select {
+	case compartment.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// compartment must be called with the mutex held. Compartments of other hosts without requests are dropped
+// whenever one is added, so hosts asked for only once don't pile up.
+func (b *bulkhead) compartment(host string) *compartment {
+	if c, ok := b.compartments[host]; ok {
+		return c
+	}
+
+	limit, ok := forHost(b.limits, host)
+	if !ok {
+		return nil
+	}
+	for other, c := range b.compartments {
+		if len(c.slots) == 0 && c.waiting == 0 {
+			delete(b.compartments, other)
+		}
+	}
+	max, _ := strconv.Atoi(limit)
+	c := &compartment{slots: make(chan struct{}, max)}
+	b.compartments[host] = c
+	return c
+}
@@ -0,0 +1,140 @@
+// Package limiter holds HttpClient decorators that limit how hard a single host is hit.
+// They can be stacked, e.g. a rate limit in front of a bulkhead.
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// QueueFullError is returned when a request would have to wait for a host whose queue is already full.
+type QueueFullError struct {
+	// Limit names the limit that was hit, "rate limit" or "in-flight limit"
+	Limit string
+	Host  string
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("%s queue for host %q is full", e.Limit, e.Host)
+}
+
+// defaultQueueSize is how many requests may wait per host unless configured otherwise
+const defaultQueueSize = 100
+
+// queueSize applies the default to a configured queue size, a negative one means requests over the limit fail
+// right away
+func queueSize(configured int) int {
+	switch {
+	case configured == 0:
+		return defaultQueueSize
+	case configured < 0:
+		return 0
+	}
+	return configured
+}
+
+// guard blocks until a request to host may be sent. release is called once the request is completed.
+type guard func(ctx context.Context, host string) (release func(), err error)
+
+type guardedClient struct {
+	client httpclient.HttpClient
+	guard  guard
+}
+
+func (c *guardedClient) Get(rawURL string) (*http.Response, error) {
+	return c.call(context.Background(), hostOf(rawURL), func() (*http.Response, error) {
+		return c.client.Get(rawURL)
+	})
+}
+
+func (c *guardedClient) Post(rawURL string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(context.Background(), hostOf(rawURL), func() (*http.Response, error) {
+		return c.client.Post(rawURL, contentType, body)
+	})
+}
+
+func (c *guardedClient) GetWithContext(ctx context.Context, rawURL string) (*http.Response, error) {
+	return c.call(ctx, hostOf(rawURL), func() (*http.Response, error) {
+		return c.client.GetWithContext(ctx, rawURL)
+	})
+}
+
+func (c *guardedClient) PostWithContext(ctx context.Context, rawURL string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(ctx, hostOf(rawURL), func() (*http.Response, error) {
+		return c.client.PostWithContext(ctx, rawURL, contentType, body)
+	})
+}
+
+func (c *guardedClient) Do(req *http.Request) (*http.Response, error) {
+	return c.call(req.Context(), hostKey(req.URL), func() (*http.Response, error) {
+		return c.client.Do(req)
+	})
+}
+
+func (c *guardedClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *guardedClient) call(ctx context.Context, host string, send func() (*http.Response, error)) (*http.Response, error) {
+	release, err := c.guard(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := send()
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	// The request is only done once the caller is finished with the body
+	resp.Body = httpclient.OnClose(resp.Body, release)
+	return resp, nil
+}
+
+// hostOf returns the host key of rawURL, an unparsable URL is left for the wrapped client to complain about
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return hostKey(parsed)
+}
+
+// hostKey is the host name of target in lower case, without port or trailing dot, so every way of writing a host
+// shares its limits
+func hostKey(target *url.URL) string {
+	return strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+}
+
+// parseHostEntries splits "host=value" entries into a map
+func parseHostEntries(entries []string) (map[string]string, error) {
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid host entry %q, expected host=value", entry)
+		}
+		values[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return values, nil
+}
+
+// forHost picks the entry for host, falling back to the "*" entry
+func forHost(entries map[string]string, host string) (string, bool) {
+	if value, ok := entries[strings.ToLower(host)]; ok {
+		return value, true
+	}
+	value, ok := entries["*"]
+	return value, ok
+}
@@ -0,0 +1,197 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/mocks"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func okResponse() *http.Response {
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}
+}
+
+func TestBulkheadClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+	inner.EXPECT().Get(gomock.Any()).DoAndReturn(func(string) (*http.Response, error) {
+		return okResponse(), nil
+	}).AnyTimes()
+
+	config := util.InfrastructureConfig{MaxInFlightPerHost: []string{"test.url.com=1"}, InFlightQueueSize: -1}
+	client, err := NewBulkheadClient(inner, errorUtil.NewErrorUtil(), config)
+	assert.NilError(t, err)
+
+	first, err := client.Get("https://test.url.com/a")
+	assert.NilError(t, err)
+
+	// The slot is taken until the body of the first response is closed and nobody may queue
+	_, err = client.Get("https://test.url.com/b")
+	var queueFull *QueueFullError
+	assert.Assert(t, errors.As(err, &queueFull))
+	assert.Equal(t, "test.url.com", queueFull.Host)
+
+	// Other hosts are not limited
+	other, err := client.Get("https://other.url.com")
+	assert.NilError(t, err)
+	other.Body.Close()
+
+	first.Body.Close()
+	second, err := client.Get("https://test.url.com/b")
+	assert.NilError(t, err)
+	second.Body.Close()
+}
+
+func TestBulkhead_DropsIdleCompartments(t *testing.T) {
+	bulkhead := &bulkhead{errorUtil: errorUtil.NewErrorUtil(), limits: map[string]string{"*": "1"}, compartments: map[string]*compartment{}}
+
+	releaseA, err := bulkhead.acquire(context.Background(), "a.url.com")
+	assert.NilError(t, err)
+	releaseB, err := bulkhead.acquire(context.Background(), "b.url.com")
+	assert.NilError(t, err)
+	releaseB()
+
+	// b has nothing in flight any more, a still has
+	releaseC, err := bulkhead.acquire(context.Background(), "c.url.com")
+	assert.NilError(t, err)
+	releaseA()
+	releaseC()
+	_, ok := bulkhead.compartments["b.url.com"]
+	assert.Assert(t, !ok)
+	assert.Equal(t, 2, len(bulkhead.compartments))
+}
+
+func TestRateLimitClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+	inner.EXPECT().GetWithContext(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, string) (*http.Response, error) {
+		return okResponse(), nil
+	}).AnyTimes()
+
+	config := util.InfrastructureConfig{RateLimits: []string{"*=20:1"}, RateLimitQueueSize: 1}
+	client, err := NewRateLimitClient(inner, errorUtil.NewErrorUtil(), config)
+	assert.NilError(t, err)
+
+	// The burst allows one request right away, the second one waits for the next token
+	start := time.Now()
+	_, err = client.GetWithContext(context.Background(), "https://test.url.com")
+	assert.NilError(t, err)
+	_, err = client.GetWithContext(context.Background(), "https://test.url.com")
+	assert.NilError(t, err)
+	assert.Assert(t, time.Since(start) >= 40*time.Millisecond)
+
+	// A cancelled wait doesn't send the request
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = client.GetWithContext(ctx, "https://test.url.com")
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRateLimiter_QueueFull(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		waiting   int
+	}{
+		{name: "Failed--Queue-Full", queueSize: 2, waiting: 2},
+		{name: "Failed--Fail-Fast", queueSize: -1, waiting: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := util.InfrastructureConfig{RateLimits: []string{"*=0.1:1"}, RateLimitQueueSize: tt.queueSize}
+			limiter, err := newRateLimiter(errorUtil.NewErrorUtil(), config)
+			assert.NilError(t, err)
+
+			release, err := limiter.wait(context.Background(), "test.url.com")
+			assert.NilError(t, err)
+			release()
+
+			// The next token is ten seconds away, the queued requests give up at the end of the test
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for i := 0; i < tt.waiting; i++ {
+				go limiter.wait(ctx, "test.url.com")
+			}
+			limiter.mutex.Lock()
+			bucket := limiter.buckets["test.url.com"]
+			limiter.mutex.Unlock()
+			for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+				bucket.mutex.Lock()
+				waiting := bucket.waiting
+				bucket.mutex.Unlock()
+				if waiting == tt.waiting || time.Now().After(deadline) {
+					break
+				}
+			}
+
+			_, err = limiter.wait(context.Background(), "test.url.com")
+			var queueFull *QueueFullError
+			assert.Assert(t, errors.As(err, &queueFull), err)
+			assert.Equal(t, "rate limit", queueFull.Limit)
+			assert.Equal(t, "test.url.com", queueFull.Host)
+
+			// Other hosts have their own bucket
+			_, err = limiter.wait(context.Background(), "other.url.com")
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestRateLimitClient_HostKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+	inner.EXPECT().GetWithContext(gomock.Any(), gomock.Any()).Return(okResponse(), nil)
+
+	config := util.InfrastructureConfig{RateLimits: []string{"test.url.com=0.1:1"}, RateLimitQueueSize: -1}
+	client, err := NewRateLimitClient(inner, errorUtil.NewErrorUtil(), config)
+	assert.NilError(t, err)
+
+	// Each is the same host, so only the first gets the single token
+	_, err = client.GetWithContext(context.Background(), "https://test.url.com/a")
+	assert.NilError(t, err)
+	for _, rawURL := range []string{"https://TEST.url.com/b", "https://test.url.com:443/c", "https://test.url.com./d"} {
+		_, err = client.GetWithContext(context.Background(), rawURL)
+		var queueFull *QueueFullError
+		assert.Assert(t, errors.As(err, &queueFull), rawURL)
+		assert.Equal(t, "test.url.com", queueFull.Host)
+	}
+}
+
+func TestRateLimiter_DropsIdleBuckets(t *testing.T) {
+	config := util.InfrastructureConfig{RateLimits: []string{"*=10:1"}}
+	limiter, err := newRateLimiter(errorUtil.NewErrorUtil(), config)
+	assert.NilError(t, err)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for _, host := range []string{"a.url.com", "b.url.com"} {
+		_, err := limiter.wait(context.Background(), host)
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, 2, len(limiter.buckets))
+
+	// Once their token is back, the buckets of a and b are no different from new ones
+	now = now.Add(100 * time.Millisecond)
+	_, err = limiter.wait(context.Background(), "c.url.com")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(limiter.buckets))
+	_, ok := limiter.buckets["c.url.com"]
+	assert.Assert(t, ok)
+}
+
+func TestNewRateLimitClient_InvalidConfig(t *testing.T) {
+	for _, limit := range []string{"test.url.com", "test.url.com=fast", "test.url.com=10:0"} {
+		_, err := NewRateLimitClient(nil, errorUtil.NewErrorUtil(), util.InfrastructureConfig{RateLimits: []string{limit}})
+		assert.Assert(t, err != nil, limit)
+	}
+}
@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// tokenBucket hands out tokens at rate per second, with up to burst tokens saved up.
+// The token count goes negative for callers that reserved a token which isn't there yet.
+type tokenBucket struct {
+	mutex   sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	waiting int
+}
+
+type rateLimiter struct {
+	errorUtil errorHelper.Helper
+	limits    map[string]string
+	queueSize int
+	now       func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimitClient returns an HttpClient that sends at most the configured number of requests per second to a host.
+// Requests over the limit wait for their turn, unless RateLimitQueueSize requests are already waiting for that host,
+// 100 if not set. A negative RateLimitQueueSize fails requests over the limit right away.
+func NewRateLimitClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig) (httpclient.HttpClient, error) {
+	limiter, err := newRateLimiter(errorUtil, config)
+	if err != nil {
+		return nil, err
+	}
+	return &guardedClient{client: client, guard: limiter.wait}, nil
+}
+
+func newRateLimiter(errorUtil errorHelper.Helper, config util.InfrastructureConfig) (*rateLimiter, error) {
+	limits, err := parseHostEntries(config.RateLimits)
+	if err != nil {
+		return nil, errorUtil.WithStack(err)
+	}
+	for host, limit := range limits {
+		if _, _, err := parseRateLimit(limit); err != nil {
+			return nil, errorUtil.WithStack(fmt.Errorf("rate limit for host %q: %w", host, err))
+		}
+	}
+
+	return &rateLimiter{
+		errorUtil: errorUtil,
+		limits:    limits,
+		queueSize: queueSize(config.RateLimitQueueSize),
+		now:       time.Now,
+		buckets:   map[string]*tokenBucket{},
+	}, nil
+}
+
+func (l *rateLimiter) wait(ctx context.Context, host string) (func(), error) {
+	// Reserving under the mutex keeps the bucket from being dropped as idle in between
+	l.mutex.Lock()
+	bucket := l.bucket(host)
+	if bucket == nil {
+		l.mutex.Unlock()
+		return func() {}, nil
+	}
+	delay, ok := bucket.reserve(l.now(), l.queueSize)
+	l.mutex.Unlock()
+
+	if !ok {
+		return nil, l.errorUtil.WithStack(&QueueFullError{Limit: "rate limit", Host: host})
+	}
+	if delay == 0 {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		bucket.done(false)
+		return func() {}, nil
+	case <-ctx.Done():
+		bucket.done(true)
+		return nil, ctx.Err()
+	}
+}
+
+// bucket must be called with the mutex held. Buckets of other hosts that went idle are dropped whenever one is
+// added, so hosts asked for only once don't pile up.
+func (l *rateLimiter) bucket(host string) *tokenBucket {
+	if bucket, ok := l.buckets[host]; ok {
+		return bucket
+	}
+
+	limit, ok := forHost(l.limits, host)
+	if !ok {
+		return nil
+	}
+
+	now := l.now()
+	for other, bucket := range l.buckets {
+		if bucket.idle(now) {
+			delete(l.buckets, other)
+		}
+	}
+	rate, burst, _ := parseRateLimit(limit)
+	bucket := &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+	l.buckets[host] = bucket
+	return bucket
+}
+
+// idle tells whether the bucket is full again with nobody waiting, so a new one would behave just the same
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.waiting == 0 && b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// reserve takes a token and returns how long to wait until it is actually available
+func (b *tokenBucket) reserve(now time.Time, queueSize int) (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	if b.waiting >= queueSize {
+		return 0, false
+	}
+
+	b.tokens--
+	b.waiting++
+	return time.Duration(-b.tokens / b.rate * float64(time.Second)), true
+}
+
+// done ends a wait, handing the reserved token back if the caller gave up
+func (b *tokenBucket) done(cancelled bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.waiting--
+	if cancelled {
+		b.tokens++
+	}
+}
+
+// parseRateLimit reads "requestsPerSecond:burst", the burst defaults to 1
+func parseRateLimit(limit string) (float64, float64, error) {
+	parts := strings.SplitN(limit, ":", 2)
+
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate <= 0 {
+		return 0, 0, fmt.Errorf("invalid requests per second %q", parts[0])
+	}
+
+	burst := 1.0
+	if len(parts) == 2 {
+		parsed, err := strconv.Atoi(parts[1])
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("invalid burst %q", parts[1])
+		}
+		burst = float64(parsed)
+	}
+
+	return rate, burst, nil
+}
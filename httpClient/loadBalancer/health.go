@@ -0,0 +1,73 @@
+package loadbalancer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+func (c *loadBalancerClient) probeLoop() {
+	defer c.probing.Done()
+
+	ticker := time.NewTicker(c.settings.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.probe()
+		}
+	}
+}
+
+// probe checks every backend once, in parallel so a hanging one doesn't hold up the others
+func (c *loadBalancerClient) probe() {
+	if c.settings.healthPath == "" {
+		c.readmit()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.settings.healthTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, b := range c.backends {
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+			c.report(b, c.check(ctx, b))
+		}(b)
+	}
+	wg.Wait()
+}
+
+func (c *loadBalancerClient) check(ctx context.Context, b *backend) bool {
+	target := b.target(&url.URL{Path: c.settings.healthPath})
+	resp, err := c.client.GetWithContext(ctx, target.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+}
+
+// readmit lets ejected backends back in when there is no health check to tell whether they recovered
+func (c *loadBalancerClient) readmit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, b := range c.backends {
+		if !b.healthy {
+			b.healthy = true
+			b.failures = 0
+		}
+	}
+}
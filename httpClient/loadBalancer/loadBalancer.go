@@ -0,0 +1,367 @@
+// Package loadbalancer spreads the requests of an HttpClient across several replicas of a service.
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// NoBackendError is returned when every backend is ejected, or all of them failed to connect for the request.
+type NoBackendError struct {
+	// Tried is the number of backends the request was sent to before giving up
+	Tried int
+	// Err is the connection error of the last backend tried, nil if none was tried
+	Err error
+}
+
+func (e *NoBackendError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("no healthy backend left after trying %d: %v", e.Tried, e.Err)
+	}
+	return fmt.Sprintf("no healthy backend left after trying %d", e.Tried)
+}
+
+func (e *NoBackendError) Unwrap() error {
+	return e.Err
+}
+
+// Backend is a snapshot of the state of one base URL.
+type Backend struct {
+	URL         string
+	Weight      int
+	Healthy     bool
+	Outstanding int
+}
+
+// Client is an HttpClient that also reports the state of its backends.
+type Client interface {
+	httpclient.HttpClient
+	Backends() []Backend
+}
+
+type backend struct {
+	base   *url.URL
+	weight int
+
+	healthy     bool
+	outstanding int
+	// currentWeight is the running weight of the smooth weighted round robin
+	currentWeight int
+	// failures and successes count consecutive outcomes, a success resets the failures and the other way round
+	failures  int
+	successes int
+}
+
+type settings struct {
+	strategy           Strategy
+	healthPath         string
+	healthInterval     time.Duration
+	healthTimeout      time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+}
+
+type loadBalancerClient struct {
+	client    httpclient.HttpClient
+	errorUtil errorHelper.Helper
+	settings  settings
+
+	mutex    sync.Mutex
+	backends []*backend
+	next     int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	probing  sync.WaitGroup
+}
+
+// NewLoadBalancerClient returns a Client sending every request to one of the backends configured in config.
+// Only the path and query of a request URL are kept, scheme and host are replaced by the chosen backend, so
+// "https://test.url.com/items?page=2" and "/items?page=2" end up at the same place.
+//
+// With LoadBalancerHealthPath set, every backend is probed with a GET of that path on each health interval.
+// A backend is ejected after LoadBalancerUnhealthyThreshold consecutive failed probes or connection errors,
+// and let back in after LoadBalancerHealthyThreshold consecutive successful probes. Without a health path an
+// ejected backend is let back in on the next interval. A request that failed to connect, and so never reached
+// the backend, is sent to the next one, unless its body can't be sent again.
+func NewLoadBalancerClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig) (Client, error) {
+	s := settings{
+		strategy:           Strategy(config.LoadBalancerStrategy),
+		healthPath:         config.LoadBalancerHealthPath,
+		healthInterval:     config.LoadBalancerHealthInterval,
+		healthTimeout:      config.LoadBalancerHealthTimeout,
+		unhealthyThreshold: config.LoadBalancerUnhealthyThreshold,
+		healthyThreshold:   config.LoadBalancerHealthyThreshold,
+	}
+	if s.strategy == "" {
+		s.strategy = RoundRobin
+	}
+	if !s.strategy.valid() {
+		return nil, fmt.Errorf("unknown load balancer strategy %q", s.strategy)
+	}
+	if s.healthInterval <= 0 {
+		s.healthInterval = 10 * time.Second
+	}
+	if s.healthTimeout <= 0 || s.healthTimeout > s.healthInterval {
+		s.healthTimeout = s.healthInterval / 2
+	}
+	if s.unhealthyThreshold <= 0 {
+		s.unhealthyThreshold = 2
+	}
+	if s.healthyThreshold <= 0 {
+		s.healthyThreshold = 2
+	}
+
+	backends, err := parseBackends(config.LoadBalancerBackends)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &loadBalancerClient{
+		client:    client,
+		errorUtil: errorUtil,
+		settings:  s,
+		backends:  backends,
+		stop:      make(chan struct{}),
+	}
+
+	c.probing.Add(1)
+	go c.probeLoop()
+
+	return c, nil
+}
+
+func (c *loadBalancerClient) Get(rawURL string) (*http.Response, error) {
+	return c.call(rawURL, true, func(target *url.URL, attempt int) (*http.Response, error) {
+		return c.client.Get(target.String())
+	})
+}
+
+func (c *loadBalancerClient) Post(rawURL string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(rawURL, true, func(target *url.URL, attempt int) (*http.Response, error) {
+		return c.client.Post(target.String(), contentType, body)
+	})
+}
+
+func (c *loadBalancerClient) GetWithContext(ctx context.Context, rawURL string) (*http.Response, error) {
+	return c.call(rawURL, true, func(target *url.URL, attempt int) (*http.Response, error) {
+		return c.client.GetWithContext(ctx, target.String())
+	})
+}
+
+func (c *loadBalancerClient) PostWithContext(ctx context.Context, rawURL string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(rawURL, true, func(target *url.URL, attempt int) (*http.Response, error) {
+		return c.client.PostWithContext(ctx, target.String(), contentType, body)
+	})
+}
+
+func (c *loadBalancerClient) Do(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	return c.call(req.URL.String(), replayable, func(target *url.URL, attempt int) (*http.Response, error) {
+		balanced := req.Clone(req.Context())
+		balanced.URL = target
+		// Let the Host header follow the backend
+		balanced.Host = ""
+
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			balanced.Body = body
+		}
+		return c.client.Do(balanced)
+	})
+}
+
+// Shutdown stops the health probes before shutting down the wrapped client.
+func (c *loadBalancerClient) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	c.probing.Wait()
+
+	return c.client.Shutdown(ctx)
+}
+
+func (c *loadBalancerClient) Backends() []Backend {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	backends := make([]Backend, 0, len(c.backends))
+	for _, b := range c.backends {
+		backends = append(backends, Backend{
+			URL:         b.base.String(),
+			Weight:      b.weight,
+			Healthy:     b.healthy,
+			Outstanding: b.outstanding,
+		})
+	}
+	return backends
+}
+
+func (c *loadBalancerClient) call(rawURL string, replayable bool, send func(target *url.URL, attempt int) (*http.Response, error)) (*http.Response, error) {
+	requested, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tried := map[*backend]bool{}
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		b := c.pick(tried)
+		if b == nil {
+			return nil, c.errorUtil.WithStack(&NoBackendError{Tried: len(tried), Err: lastErr})
+		}
+		tried[b] = true
+
+		resp, err := send(b.target(requested), attempt)
+		if err != nil {
+			c.release(b)
+			if !isConnectionError(err) {
+				return resp, err
+			}
+
+			c.report(b, false)
+			if !replayable {
+				return resp, err
+			}
+			lastErr = err
+			continue
+		}
+
+		c.report(b, true)
+		if resp.Body == nil {
+			c.release(b)
+			return resp, nil
+		}
+		// The request is outstanding until the caller is finished with the body
+		resp.Body = httpclient.OnClose(resp.Body, func() { c.release(b) })
+		return resp, nil
+	}
+}
+
+// pick chooses a healthy backend that wasn't tried yet and counts the request as outstanding on it
+func (c *loadBalancerClient) pick(tried map[*backend]bool) *backend {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	candidates := make([]*backend, 0, len(c.backends))
+	for _, b := range c.backends {
+		if b.healthy && !tried[b] {
+			candidates = append(candidates, b)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	b := c.choose(candidates)
+	b.outstanding++
+	return b
+}
+
+func (c *loadBalancerClient) release(b *backend) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b.outstanding--
+}
+
+// report records the outcome of a request or probe, ejecting or restoring the backend once a threshold is reached
+func (c *loadBalancerClient) report(b *backend, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if ok {
+		b.failures = 0
+		b.successes++
+		if !b.healthy && b.successes >= c.settings.healthyThreshold {
+			b.healthy = true
+		}
+		return
+	}
+
+	b.successes = 0
+	b.failures++
+	if b.healthy && b.failures >= c.settings.unhealthyThreshold {
+		b.healthy = false
+		b.currentWeight = 0
+	}
+}
+
+func (b *backend) target(requested *url.URL) *url.URL {
+	target := *b.base
+	if requested.Path != "" {
+		target.Path = strings.TrimSuffix(b.base.Path, "/") + "/" + strings.TrimPrefix(requested.Path, "/")
+		target.RawPath = ""
+	}
+	target.RawQuery = requested.RawQuery
+	return &target
+}
+
+// isConnectionError tells whether err means the request never reached the backend, so it is safe to send it
+// to another one whatever its method. Only dials failed by the network count, not ones the client refused itself,
+// like a denied egress, as those would fail on every backend without any of them being at fault.
+func isConnectionError(err error) bool {
+	var egressErr *nethttp.EgressError
+	if errors.As(err, &egressErr) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		var errno syscall.Errno
+		var dnsErr *net.DNSError
+		return opErr.Timeout() || errors.As(opErr.Err, &errno) || errors.As(opErr.Err, &dnsErr)
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// parseBackends reads "url" or "url=weight" entries, the weight defaults to 1
+func parseBackends(entries []string) ([]*backend, error) {
+	backends := make([]*backend, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rawURL, weight := entry, 1
+		// A URL may contain "=" in its query, so only a trailing number counts as weight
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			if parsed, err := strconv.Atoi(entry[i+1:]); err == nil {
+				if parsed <= 0 {
+					return nil, fmt.Errorf("invalid weight in backend %q, must be positive", entry)
+				}
+				rawURL, weight = entry[:i], parsed
+			}
+		}
+
+		base, err := url.Parse(rawURL)
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			return nil, fmt.Errorf("invalid backend %q, expected an absolute URL", entry)
+		}
+		backends = append(backends, &backend{base: base, weight: weight, healthy: true})
+	}
+
+	if len(backends) == 0 {
+		return nil, errors.New("no load balancer backends configured")
+	}
+	return backends, nil
+}
@@ -0,0 +1,219 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"gotest.tools/assert"
+)
+
+var backendURLs = []string{"http://a.local", "http://b.local", "http://c.local"}
+
+func newClient(t *testing.T, inner *fake.Client, config util.InfrastructureConfig) *loadBalancerClient {
+	// Probes are run by hand in the tests
+	config.LoadBalancerHealthInterval = time.Hour
+	client, err := NewLoadBalancerClient(inner, errorUtil.NewErrorUtil(), config)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		client.Shutdown(context.Background())
+	})
+	return client.(*loadBalancerClient)
+}
+
+func newBackends() *fake.Client {
+	inner := fake.NewFakeClient()
+	for _, backend := range backendURLs {
+		inner.On("*", backend+"*").Respond(http.StatusOK, "")
+	}
+	return inner
+}
+
+func hostsOf(calls []fake.Call) []string {
+	hosts := make([]string, 0, len(calls))
+	for _, call := range calls {
+		parsed, _ := url.Parse(call.URL)
+		hosts = append(hosts, parsed.Host)
+	}
+	return hosts
+}
+
+func TestLoadBalancerClient_Strategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		backends []string
+		want     []string
+	}{
+		{
+			name:     "Successful--RoundRobin",
+			strategy: "round_robin",
+			backends: backendURLs,
+			want:     []string{"a.local", "b.local", "c.local", "a.local", "b.local", "c.local"},
+		},
+		{
+			name:     "Successful--Weighted",
+			strategy: "weighted",
+			backends: []string{"http://a.local=3", "http://b.local=1"},
+			want:     []string{"a.local", "a.local", "b.local", "a.local", "a.local", "a.local", "b.local", "a.local"},
+		},
+		{
+			name:     "Successful--LeastOutstandingWithoutLoad",
+			strategy: "least_outstanding",
+			backends: backendURLs,
+			want:     []string{"a.local", "b.local", "c.local", "a.local"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newBackends()
+			client := newClient(t, inner, util.InfrastructureConfig{LoadBalancerBackends: tt.backends, LoadBalancerStrategy: tt.strategy})
+
+			for range tt.want {
+				resp, err := client.Get("https://test.url.com/items?page=2")
+				assert.NilError(t, err)
+				resp.Body.Close()
+			}
+
+			assert.DeepEqual(t, tt.want, hostsOf(inner.Calls()))
+			assert.Equal(t, "http://a.local/items?page=2", inner.Calls()[0].URL)
+		})
+	}
+}
+
+func TestLoadBalancerClient_LeastOutstanding(t *testing.T) {
+	inner := newBackends()
+	client := newClient(t, inner, util.InfrastructureConfig{LoadBalancerBackends: backendURLs[:2], LoadBalancerStrategy: "least_outstanding"})
+
+	// The first response is held open, so a.local stays busy until it is closed
+	held, err := client.Get("/slow")
+	assert.NilError(t, err)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("/fast")
+		assert.NilError(t, err)
+		resp.Body.Close()
+	}
+	assert.DeepEqual(t, []string{"a.local", "b.local", "b.local", "b.local"}, hostsOf(inner.Calls()))
+	assert.Equal(t, 1, client.Backends()[0].Outstanding)
+
+	held.Body.Close()
+	assert.Equal(t, 0, client.Backends()[0].Outstanding)
+}
+
+func TestLoadBalancerClient_Failover(t *testing.T) {
+	inner := newBackends()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	inner.On("*", "http://a.local*").Fail(refused)
+	client := newClient(t, inner, util.InfrastructureConfig{LoadBalancerBackends: backendURLs[:2]})
+
+	// Both requests fail to connect to a.local and go on to b.local, which ejects a.local after the second
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post("/items", "application/json", []byte(`{"key":"value"}`))
+		assert.NilError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, false, client.Backends()[0].Healthy)
+
+	resp, err := client.Get("/items")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, []string{"a.local", "b.local", "a.local", "b.local", "b.local"}, hostsOf(inner.Calls()))
+
+	// Other errors reached the backend and are returned as they are
+	inner.On("*", "http://b.local*").Fail(errors.New("connection reset"))
+	_, err = client.Get("/items")
+	assert.Error(t, err, "connection reset")
+}
+
+func TestLoadBalancerClient_NoBackend(t *testing.T) {
+	inner := fake.NewFakeClient()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	inner.On("*", "*").Fail(refused)
+	client := newClient(t, inner, util.InfrastructureConfig{LoadBalancerBackends: backendURLs[:2], LoadBalancerUnhealthyThreshold: 1})
+
+	// Both backends refused the connection, the last error is kept
+	_, err := client.Get("/items")
+	var noBackend *NoBackendError
+	assert.Assert(t, errors.As(err, &noBackend))
+	assert.Equal(t, 2, noBackend.Tried)
+	assert.Assert(t, errors.Is(err, syscall.ECONNREFUSED))
+
+	// Now both are ejected and nothing is tried
+	_, err = client.Get("/items")
+	assert.Assert(t, errors.As(err, &noBackend))
+	assert.Equal(t, 0, noBackend.Tried)
+	assert.Assert(t, noBackend.Err == nil)
+}
+
+func TestLoadBalancerClient_EgressDenied(t *testing.T) {
+	inner := fake.NewFakeClient()
+	denied := &net.OpError{Op: "dial", Net: "tcp", Err: &nethttp.EgressError{Host: "a.local", Reason: "host is not allowed"}}
+	inner.On("*", "*").Fail(denied)
+	client := newClient(t, inner, util.InfrastructureConfig{LoadBalancerBackends: backendURLs[:2], LoadBalancerUnhealthyThreshold: 1})
+
+	// The client itself refused to connect, so neither is the backend ejected nor another one tried
+	_, err := client.Get("/items")
+	var egressErr *nethttp.EgressError
+	assert.Assert(t, errors.As(err, &egressErr))
+	assert.Equal(t, 1, len(inner.Calls()))
+	for _, backend := range client.Backends() {
+		assert.Assert(t, backend.Healthy, backend.URL)
+	}
+}
+
+func TestLoadBalancerClient_HealthProbes(t *testing.T) {
+	inner := newBackends()
+	inner.On(http.MethodGet, "http://a.local/health").Respond(http.StatusServiceUnavailable, "")
+	client := newClient(t, inner, util.InfrastructureConfig{
+		LoadBalancerBackends:         backendURLs[:2],
+		LoadBalancerHealthPath:       "/health",
+		LoadBalancerHealthyThreshold: 3,
+	})
+
+	client.probe()
+	assert.Equal(t, true, client.Backends()[0].Healthy)
+	client.probe()
+	assert.Equal(t, false, client.Backends()[0].Healthy)
+	assert.Equal(t, true, client.Backends()[1].Healthy)
+
+	inner.On(http.MethodGet, "http://a.local/health").Respond(http.StatusOK, "")
+	client.probe()
+	client.probe()
+	assert.Equal(t, false, client.Backends()[0].Healthy)
+	client.probe()
+	assert.Equal(t, true, client.Backends()[0].Healthy)
+}
+
+func TestLoadBalancerClient_ReadmitWithoutHealthPath(t *testing.T) {
+	client := newClient(t, newBackends(), util.InfrastructureConfig{LoadBalancerBackends: backendURLs})
+
+	client.report(client.backends[0], false)
+	client.report(client.backends[0], false)
+	assert.Equal(t, false, client.Backends()[0].Healthy)
+
+	client.probe()
+	assert.Equal(t, true, client.Backends()[0].Healthy)
+}
+
+func TestNewLoadBalancerClient_InvalidConfig(t *testing.T) {
+	configs := []util.InfrastructureConfig{
+		{},
+		{LoadBalancerBackends: []string{"a.local"}},
+		{LoadBalancerBackends: []string{"http://a.local=0"}},
+		{LoadBalancerBackends: backendURLs, LoadBalancerStrategy: "random"},
+	}
+	for _, config := range configs {
+		_, err := NewLoadBalancerClient(nil, errorUtil.NewErrorUtil(), config)
+		assert.Assert(t, err != nil, config)
+	}
+}
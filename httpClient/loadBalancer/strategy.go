@@ -0,0 +1,55 @@
+package loadbalancer
+
+// Strategy decides which of the healthy backends gets the next request.
+type Strategy string
+
+const (
+	// RoundRobin takes turns through the backends
+	RoundRobin Strategy = "round_robin"
+	// LeastOutstanding picks the backend with the fewest requests in flight, taking turns between equal ones
+	LeastOutstanding Strategy = "least_outstanding"
+	// Weighted takes turns in proportion to the weights, spread evenly like the smooth weighted round robin of nginx
+	Weighted Strategy = "weighted"
+)
+
+func (s Strategy) valid() bool {
+	switch s {
+	case RoundRobin, LeastOutstanding, Weighted:
+		return true
+	}
+	return false
+}
+
+// choose must be called with the mutex held and at least one candidate
+func (c *loadBalancerClient) choose(candidates []*backend) *backend {
+	switch c.settings.strategy {
+	case LeastOutstanding:
+		offset := c.next
+		c.next++
+
+		var chosen *backend
+		for i := range candidates {
+			b := candidates[(offset+i)%len(candidates)]
+			if chosen == nil || b.outstanding < chosen.outstanding {
+				chosen = b
+			}
+		}
+		return chosen
+	case Weighted:
+		total := 0
+		var chosen *backend
+		for _, b := range candidates {
+			b.currentWeight += b.weight
+			total += b.weight
+			if chosen == nil || b.currentWeight > chosen.currentWeight {
+				chosen = b
+			}
+		}
+		chosen.currentWeight -= total
+		return chosen
+	default:
+		chosen := candidates[c.next%len(candidates)]
+		c.next++
+		return chosen
+	}
+}
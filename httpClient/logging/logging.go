@@ -0,0 +1,344 @@
+// Package logging logs outbound requests of an HttpClient with secrets redacted.
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/middleware"
+	jsonHandler "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultRedactHeaders are always redacted, HttpLogRedactHeaders adds to them
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// defaultRedactKeys are always redacted in query strings, form bodies and JSON objects at any depth, the last part
+// of every HttpLogRedactJSONFields path adds to them
+var defaultRedactKeys = []string{"password", "client_secret", "access_token", "refresh_token", "id_token", "token", "api_key", "apikey"}
+
+// Logger is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type requestLogger struct {
+	logger        Logger
+	jsonHandler   jsonHandler.JSONHandler
+	logHeaders    bool
+	maxBodyBytes  int
+	redactHeaders map[string]bool
+	redactFields  [][]string
+	redactKeys    map[string]bool
+	now           func() time.Time
+}
+
+// NewLoggingMiddleware returns a Middleware that writes one line per request to logger, with method, URL, status,
+// duration and body sizes. Responses are logged once their body is closed, as only then the size is known.
+func NewLoggingMiddleware(logger Logger, jsonHandler jsonHandler.JSONHandler, config util.InfrastructureConfig) middleware.Middleware {
+	l := &requestLogger{
+		logger:        logger,
+		jsonHandler:   jsonHandler,
+		logHeaders:    config.HttpLogHeaders,
+		maxBodyBytes:  config.HttpLogBodyBytes,
+		redactHeaders: map[string]bool{},
+		redactKeys:    map[string]bool{},
+		now:           time.Now,
+	}
+	for _, header := range append(defaultRedactHeaders, config.HttpLogRedactHeaders...) {
+		l.redactHeaders[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+	for _, key := range defaultRedactKeys {
+		l.redactKeys[key] = true
+	}
+	for _, field := range config.HttpLogRedactJSONFields {
+		if field = strings.TrimSpace(field); field != "" {
+			path := strings.Split(field, ".")
+			l.redactFields = append(l.redactFields, path)
+			l.redactKeys[strings.ToLower(path[len(path)-1])] = true
+		}
+	}
+
+	return l.middleware
+}
+
+// NewLoggingClient wraps client with the logging middleware only.
+func NewLoggingClient(client httpclient.HttpClient, logger Logger, jsonHandler jsonHandler.JSONHandler, config util.InfrastructureConfig) httpclient.HttpClient {
+	return middleware.NewChainClient(client, NewLoggingMiddleware(logger, jsonHandler, config))
+}
+
+func (l *requestLogger) middleware(next middleware.Handler) middleware.Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		start := l.now()
+
+		var requestBody *capture
+		if req.Body != nil && req.Body != http.NoBody {
+			requestBody = &capture{ReadCloser: req.Body, max: l.maxBodyBytes}
+			req = req.Clone(req.Context())
+			req.Body = requestBody
+		}
+
+		resp, err := next(req)
+		entry := &entry{
+			logger:      l,
+			req:         req,
+			resp:        resp,
+			err:         err,
+			duration:    l.now().Sub(start),
+			requestBody: requestBody,
+		}
+
+		if err != nil || resp == nil || resp.Body == nil {
+			entry.log()
+			return resp, err
+		}
+
+		entry.responseBody = &capture{ReadCloser: resp.Body, max: l.maxBodyBytes, onClose: entry.log}
+		resp.Body = entry.responseBody
+		return resp, nil
+	}
+}
+
+type entry struct {
+	logger       *requestLogger
+	req          *http.Request
+	resp         *http.Response
+	err          error
+	duration     time.Duration
+	requestBody  *capture
+	responseBody *capture
+	once         sync.Once
+}
+
+func (e *entry) log() {
+	e.once.Do(func() {
+		l := e.logger
+
+		var line strings.Builder
+		fmt.Fprintf(&line, "http request method=%s url=%q", e.req.Method, l.url(e.req.URL))
+		if e.err != nil {
+			fmt.Fprintf(&line, " error=%q", e.err.Error())
+		} else if e.resp != nil {
+			fmt.Fprintf(&line, " status=%d", e.resp.StatusCode)
+		}
+		fmt.Fprintf(&line, " duration=%s request_bytes=%d", e.duration, e.requestBody.size())
+		if e.responseBody != nil {
+			fmt.Fprintf(&line, " response_bytes=%d", e.responseBody.size())
+		}
+
+		if l.logHeaders {
+			fmt.Fprintf(&line, " request_headers=%q", l.headers(e.req.Header))
+			if e.resp != nil {
+				fmt.Fprintf(&line, " response_headers=%q", l.headers(e.resp.Header))
+			}
+		}
+
+		if l.maxBodyBytes > 0 {
+			fmt.Fprintf(&line, " request_body=%q", l.body(e.req.Header, e.requestBody))
+			if e.resp != nil {
+				fmt.Fprintf(&line, " response_body=%q", l.body(e.resp.Header, e.responseBody))
+			}
+		}
+
+		l.logger.Printf("%s", line.String())
+	})
+}
+
+func (l *requestLogger) headers(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Join(header[key], ", ")
+		if l.redactHeaders[http.CanonicalHeaderKey(key)] {
+			value = redacted
+		}
+		pairs = append(pairs, key+": "+value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// body returns the captured body, with the redacted keys and the configured JSON fields redacted.
+// A JSON body that can't be redacted, e.g. because it was cut off, is left out altogether.
+func (l *requestLogger) body(header http.Header, body *capture) string {
+	if body == nil {
+		return ""
+	}
+	data, truncated, size := body.snapshot()
+	if len(data) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		redactedBody := l.params(string(data))
+		if truncated {
+			return redactedBody + "..."
+		}
+		return redactedBody
+	}
+
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if !isJSON {
+		if truncated {
+			return string(data) + "..."
+		}
+		return string(data)
+	}
+
+	if truncated {
+		return fmt.Sprintf("[%d bytes of JSON omitted, too large to redact]", size)
+	}
+
+	var document interface{}
+	if err := l.jsonHandler.Unmarshal(data, &document); err != nil {
+		return fmt.Sprintf("[%d bytes of invalid JSON omitted]", size)
+	}
+	l.redactKeysIn(document)
+	for _, path := range l.redactFields {
+		redactPath(document, path)
+	}
+
+	redactedBody, err := l.jsonHandler.Marshal(document)
+	if err != nil {
+		return fmt.Sprintf("[%d bytes of JSON omitted]", size)
+	}
+	return string(redactedBody)
+}
+
+// redactKeysIn replaces the values of every object key in the redacted keys, however deep it is nested
+func (l *requestLogger) redactKeysIn(document interface{}) {
+	switch value := document.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if l.redactKeys[strings.ToLower(key)] {
+				value[key] = redacted
+				continue
+			}
+			l.redactKeysIn(child)
+		}
+	case []interface{}:
+		for _, element := range value {
+			l.redactKeysIn(element)
+		}
+	}
+}
+
+// url returns target without its password and with the values of redacted query parameters replaced
+func (l *requestLogger) url(target *url.URL) string {
+	if target.RawQuery == "" {
+		return target.Redacted()
+	}
+	redactedURL := *target
+	redactedURL.RawQuery = l.params(target.RawQuery)
+	return redactedURL.Redacted()
+}
+
+// params redacts the values of a query string or form body, keeping everything else as it was sent
+func (l *requestLogger) params(encoded string) string {
+	pairs := strings.Split(encoded, "&")
+	for i, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(parts[0])
+		if err != nil {
+			key = parts[0]
+		}
+		if len(parts) == 2 && l.redactKeys[strings.ToLower(key)] {
+			pairs[i] = parts[0] + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactPath replaces the value at path, arrays on the way apply the rest of the path to every element
+func redactPath(document interface{}, path []string) {
+	switch value := document.(type) {
+	case map[string]interface{}:
+		child, ok := value[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			value[path[0]] = redacted
+			return
+		}
+		redactPath(child, path[1:])
+	case []interface{}:
+		for _, element := range value {
+			redactPath(element, path)
+		}
+	}
+}
+
+// capture counts the bytes read through it and keeps the first max of them. The transport may still be sending
+// a request body while the entry is logged, so everything it records is guarded by the mutex.
+type capture struct {
+	io.ReadCloser
+	max     int
+	onClose func()
+
+	mutex     sync.Mutex
+	buffer    bytes.Buffer
+	read      int64
+	truncated bool
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.read += int64(n)
+	if room := c.max - c.buffer.Len(); room > 0 {
+		if n > room {
+			c.buffer.Write(p[:room])
+			c.truncated = true
+		} else {
+			c.buffer.Write(p[:n])
+		}
+	} else if n > 0 {
+		c.truncated = true
+	}
+
+	return n, err
+}
+
+func (c *capture) Close() error {
+	err := c.ReadCloser.Close()
+	if c.onClose != nil {
+		c.onClose()
+	}
+	return err
+}
+
+func (c *capture) size() int64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.read
+}
+
+// snapshot returns a copy of what was captured so far, whether more was read than kept, and how much was read
+func (c *capture) snapshot() ([]byte, bool, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]byte{}, c.buffer.Bytes()...), c.truncated, c.read
+}
@@ -0,0 +1,104 @@
+// Package middleware puts an ordered chain of interceptors in front of an HttpClient.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// Handler sends a request and returns its response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the next Handler of the chain. It may change the request before passing it on,
+// and the response or error on the way back, or not call next at all.
+type Middleware func(next Handler) Handler
+
+// NewChainClient returns an HttpClient which passes every request through middlewares, in the given order,
+// before sending it with client.Do. All methods end up as a call to client.Do, so with a MockHttpClient
+// the expectations have to be set on Do.
+func NewChainClient(client httpclient.HttpClient, middlewares ...Middleware) httpclient.HttpClient {
+	handler := Handler(client.Do)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return &handlerClient{handler: handler, shutdown: client.Shutdown}
+}
+
+// RequestInterceptor returns a Middleware that calls intercept with every outgoing request.
+// An error from intercept is returned without sending the request.
+func RequestInterceptor(intercept func(req *http.Request) error) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if err := intercept(req); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+// ResponseInterceptor returns a Middleware that lets intercept inspect or replace the response and error of every request.
+func ResponseInterceptor(intercept func(req *http.Request, resp *http.Response, err error) (*http.Response, error)) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			return intercept(req, resp, err)
+		}
+	}
+}
+
+// Decorate turns an HttpClient decorator, e.g. a retry or circuit breaker client, into a Middleware.
+// The decorator is created once per chain, so its state is shared by all requests going through the chain.
+func Decorate(decorator func(client httpclient.HttpClient) httpclient.HttpClient) Middleware {
+	return func(next Handler) Handler {
+		return decorator(&handlerClient{handler: next, shutdown: func(context.Context) error { return nil }}).Do
+	}
+}
+
+// handlerClient implements HttpClient on top of a single Handler
+type handlerClient struct {
+	handler  Handler
+	shutdown func(ctx context.Context) error
+}
+
+func (c *handlerClient) Get(url string) (*http.Response, error) {
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *handlerClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.PostWithContext(context.Background(), url, contentType, body)
+}
+
+func (c *handlerClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodGet, url).WithContext(ctx).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.handler(req)
+}
+
+func (c *handlerClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	req, err := httpclient.NewRequestBuilder(http.MethodPost, url).
+		WithContext(ctx).
+		Header("Content-Type", contentType).
+		Body(bytes.NewReader(body)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.handler(req)
+}
+
+func (c *handlerClient) Do(req *http.Request) (*http.Response, error) {
+	return c.handler(req)
+}
+
+func (c *handlerClient) Shutdown(ctx context.Context) error {
+	return c.shutdown(ctx)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/mocks"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/retry"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func TestChainClient_Order(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+
+	var order []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+"-request")
+				resp, err := next(req)
+				order = append(order, name+"-response")
+				return resp, err
+			}
+		}
+	}
+
+	inner.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Equal(t, `{"key":"value"}`, string(body))
+		return &http.Response{StatusCode: 201}, nil
+	})
+
+	client := NewChainClient(inner,
+		record("first"),
+		RequestInterceptor(func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer token")
+			return nil
+		}),
+		record("second"),
+	)
+	resp, err := client.Post("https://test.url.com", "application/json", []byte(`{"key":"value"}`))
+
+	assert.NilError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.DeepEqual(t, []string{"first-request", "second-request", "second-response", "first-response"}, order)
+}
+
+func TestChainClient_Interceptors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+
+	// A failing request interceptor never reaches the wrapped client
+	client := NewChainClient(inner, RequestInterceptor(func(req *http.Request) error {
+		return errors.New("not allowed")
+	}))
+	_, err := client.Get("https://test.url.com")
+	assert.ErrorContains(t, err, "not allowed")
+
+	// A response interceptor can turn an error into a response
+	inner.EXPECT().Do(gomock.Any()).Return(nil, errors.New("server error"))
+	client = NewChainClient(inner, ResponseInterceptor(func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+	}))
+	resp, err := client.Get("https://test.url.com")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestDecorate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+	gomock.InOrder(
+		inner.EXPECT().Do(gomock.Any()).Return(nil, io.ErrUnexpectedEOF),
+		inner.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: 200}, nil),
+	)
+
+	client := NewChainClient(inner, Decorate(func(next httpclient.HttpClient) httpclient.HttpClient {
+		return retry.NewRetryClient(next, retry.Config{BaseDelay: time.Millisecond})
+	}))
+	resp, err := client.Get("https://test.url.com")
+
+	assert.NilError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	http "net/http"
 	reflect "reflect"
 
@@ -34,6 +35,21 @@ func (m *MockHttpClient) EXPECT() *MockHttpClientMockRecorder {
 	return m.recorder
 }
 
+// Do mocks base method.
+func (m *MockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", req)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockHttpClientMockRecorder) Do(req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockHttpClient)(nil).Do), req)
+}
+
 // Get mocks base method.
 func (m *MockHttpClient) Get(url string) (*http.Response, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +65,21 @@ func (mr *MockHttpClientMockRecorder) Get(url interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockHttpClient)(nil).Get), url)
 }
 
+// GetWithContext mocks base method.
+func (m *MockHttpClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithContext", ctx, url)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithContext indicates an expected call of GetWithContext.
+func (mr *MockHttpClientMockRecorder) GetWithContext(ctx, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithContext", reflect.TypeOf((*MockHttpClient)(nil).GetWithContext), ctx, url)
+}
+
 // Post mocks base method.
 func (m *MockHttpClient) Post(url, contentType string, body []byte) (*http.Response, error) {
 	m.ctrl.T.Helper()
@@ -64,14 +95,31 @@ func (mr *MockHttpClientMockRecorder) Post(url, contentType, body interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Post", reflect.TypeOf((*MockHttpClient)(nil).Post), url, contentType, body)
 }
 
+// PostWithContext mocks base method.
+func (m *MockHttpClient) PostWithContext(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostWithContext", ctx, url, contentType, body)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostWithContext indicates an expected call of PostWithContext.
+func (mr *MockHttpClientMockRecorder) PostWithContext(ctx, url, contentType, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostWithContext", reflect.TypeOf((*MockHttpClient)(nil).PostWithContext), ctx, url, contentType, body)
+}
+
 // Shutdown mocks base method.
-func (m *MockHttpClient) Shutdown() {
+func (m *MockHttpClient) Shutdown(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Shutdown")
+	ret := m.ctrl.Call(m, "Shutdown", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Shutdown indicates an expected call of Shutdown.
-func (mr *MockHttpClientMockRecorder) Shutdown() *gomock.Call {
+func (mr *MockHttpClientMockRecorder) Shutdown(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockHttpClient)(nil).Shutdown))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockHttpClient)(nil).Shutdown), ctx)
 }
@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+)
+
+// MultipartForm is a multipart/form-data body that is streamed part by part while the request is sent,
+// so files don't have to fit into memory. As the contents are read only once, such a body can't be sent again,
+// requests with it are neither retried nor hedged.
+type MultipartForm struct {
+	boundary string
+	parts    []formPart
+}
+
+type formPart struct {
+	fieldName string
+	fileName  string
+	value     string
+	content   io.Reader
+}
+
+func NewMultipartForm() *MultipartForm {
+	return &MultipartForm{
+		boundary: multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+// Field adds a plain form field.
+func (f *MultipartForm) Field(name string, value string) *MultipartForm {
+	f.parts = append(f.parts, formPart{fieldName: name, value: value})
+	return f
+}
+
+// File adds a file upload, content is read when the body is sent. If content is an io.ReadCloser, it is closed
+// once the form was written, or when writing it stopped early.
+func (f *MultipartForm) File(fieldName string, fileName string, content io.Reader) *MultipartForm {
+	f.parts = append(f.parts, formPart{fieldName: fieldName, fileName: fileName, content: content})
+	return f
+}
+
+// ContentType is the value for the Content-Type header, including the boundary.
+func (f *MultipartForm) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+// Reader returns the encoded form. The parts are written in the background as the reader is consumed,
+// closing the reader early stops that.
+func (f *MultipartForm) Reader() io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(f.write(writer))
+	}()
+
+	return reader
+}
+
+func (f *MultipartForm) write(w io.Writer) error {
+	defer f.close()
+
+	form := multipart.NewWriter(w)
+	if err := form.SetBoundary(f.boundary); err != nil {
+		return err
+	}
+
+	for _, part := range f.parts {
+		if part.content == nil {
+			if err := form.WriteField(part.fieldName, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		partWriter, err := form.CreateFormFile(part.fieldName, part.fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(partWriter, part.content); err != nil {
+			return err
+		}
+	}
+
+	return form.Close()
+}
+
+// close closes the contents that are closers, whether they were sent or not
+func (f *MultipartForm) close() {
+	for _, part := range f.parts {
+		if closer, ok := part.content.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
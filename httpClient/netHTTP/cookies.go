@@ -0,0 +1,163 @@
+package nethttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// WithCookieJar keeps the cookies set by servers and sends them along with later requests, for example
+// one from NewMemoryCookieJar or NewFileCookieJar.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(o *options) {
+		o.jar = jar
+	}
+}
+
+// NewMemoryCookieJar returns a cookie jar that lives as long as the client, using the public suffix list so
+// a server can't set cookies for a whole top level domain.
+func NewMemoryCookieJar() http.CookieJar {
+	// cookiejar.New only fails for options it doesn't get
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+// storedCookie is a cookie together with the URL that set it, which decides where it is sent to
+type storedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+type fileCookieJar struct {
+	http.CookieJar
+	path string
+	now  func() time.Time
+
+	mutex   sync.Mutex
+	cookies map[string]storedCookie
+}
+
+// NewFileCookieJar returns a cookie jar that is written to path whenever a server sets a cookie, and that starts
+// out with the cookies stored there, so a session survives a restart. Session cookies are stored as well.
+func NewFileCookieJar(path string) (http.CookieJar, error) {
+	jar := &fileCookieJar{
+		CookieJar: NewMemoryCookieJar(),
+		path:      path,
+		now:       time.Now,
+		cookies:   map[string]storedCookie{},
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie jar: %w", err)
+	}
+
+	var stored []storedCookie
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("reading cookie jar %q: %w", path, err)
+	}
+	for _, entry := range stored {
+		target, err := url.Parse(entry.URL)
+		if err != nil || entry.Cookie == nil || jar.expired(entry.Cookie) {
+			continue
+		}
+		jar.CookieJar.SetCookies(target, []*http.Cookie{entry.Cookie})
+		jar.cookies[cookieKey(target, entry.Cookie)] = entry
+	}
+	return jar, nil
+}
+
+func (j *fileCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.CookieJar.SetCookies(u, cookies)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	// Only scheme and host matter for where a cookie is sent, besides its own Domain and Path
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	for _, cookie := range cookies {
+		// Max-Age counts from when the cookie was set, it would start over on every reload if stored as is
+		stored := *cookie
+		if stored.MaxAge > 0 {
+			stored.Expires = j.now().Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		j.cookies[cookieKey(u, cookie)] = storedCookie{URL: origin.String(), Cookie: &stored}
+	}
+	j.save()
+}
+
+// save must be called with the mutex held. The jar keeps working from memory if the file can't be written.
+func (j *fileCookieJar) save() {
+	stored := make([]storedCookie, 0, len(j.cookies))
+	for key, entry := range j.cookies {
+		if j.expired(entry.Cookie) {
+			delete(j.cookies, key)
+			continue
+		}
+		stored = append(stored, entry)
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file first, so a crash never leaves half a jar behind
+	file, err := ioutil.TempFile(filepath.Dir(j.path), "cookies-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return
+	}
+	if err := file.Close(); err != nil {
+		return
+	}
+	os.Rename(file.Name(), j.path)
+}
+
+func (j *fileCookieJar) expired(cookie *http.Cookie) bool {
+	return cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && !cookie.Expires.After(j.now()))
+}
+
+// cookieKey identifies a cookie the way a jar does, a cookie with the same key replaces the stored one
+func cookieKey(u *url.URL, cookie *http.Cookie) string {
+	domain := cookie.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	return cookie.Name + ";" + domain + ";" + cookie.Path
+}
+
+// withCookieJarConfig applies the cookie jar settings of the config
+func withCookieJarConfig(enabled bool, path string) Option {
+	return func(o *options) {
+		switch {
+		case path != "":
+			jar, err := NewFileCookieJar(path)
+			if err != nil {
+				o.fail(err)
+				return
+			}
+			WithCookieJar(jar)(o)
+		case enabled:
+			WithCookieJar(NewMemoryCookieJar())(o)
+		}
+	}
+}
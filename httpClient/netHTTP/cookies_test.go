@@ -0,0 +1,113 @@
+package nethttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"gotest.tools/assert"
+)
+
+// newSessionServer sets a session cookie on /login, removes it on /logout and answers /profile with it
+func newSessionServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+		case "/profile":
+			if cookie, err := r.Cookie("session"); err == nil {
+				w.Write([]byte(cookie.Value))
+			}
+		}
+	}))
+}
+
+func get(t *testing.T, client httpclient.HttpClient, url string) string {
+	resp, err := client.Get(url)
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestNetHttpClient_CookieJar(t *testing.T) {
+	server := newSessionServer()
+	defer server.Close()
+
+	withoutJar, err := NewNetHttpClient()
+	assert.NilError(t, err)
+	get(t, withoutJar, server.URL+"/login")
+	assert.Equal(t, "", get(t, withoutJar, server.URL+"/profile"))
+
+	withJar, err := NewNetHttpClient(WithCookieJar(NewMemoryCookieJar()))
+	assert.NilError(t, err)
+	get(t, withJar, server.URL+"/login")
+	assert.Equal(t, "abc", get(t, withJar, server.URL+"/profile"))
+}
+
+func TestNetHttpClient_FileCookieJar(t *testing.T) {
+	server := newSessionServer()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cookies.json")
+
+	// The session of the first client is picked up by the second one
+	first, err := NewNetHttpClient(withCookieJarConfig(false, path))
+	assert.NilError(t, err)
+	get(t, first, server.URL+"/login")
+
+	second, err := NewNetHttpClient(withCookieJarConfig(false, path))
+	assert.NilError(t, err)
+	assert.Equal(t, "abc", get(t, second, server.URL+"/profile"))
+
+	// Deleted cookies are gone from the file as well
+	get(t, second, server.URL+"/logout")
+	third, err := NewNetHttpClient(withCookieJarConfig(false, path))
+	assert.NilError(t, err)
+	assert.Equal(t, "", get(t, third, server.URL+"/profile"))
+}
+
+func TestNewFileCookieJar_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	target, _ := url.Parse("https://test.url.com/")
+	now := time.Now()
+
+	jar, err := NewFileCookieJar(path)
+	assert.NilError(t, err)
+	jar.(*fileCookieJar).now = func() time.Time { return now.Add(-2 * time.Minute) }
+	jar.SetCookies(target, []*http.Cookie{
+		{Name: "expired", Value: "old", MaxAge: 60},
+		{Name: "valid", Value: "new", MaxAge: 3600},
+	})
+
+	// Max-Age is stored as the point in time it ends, so it doesn't start over on reload
+	reloaded, err := NewFileCookieJar(path)
+	assert.NilError(t, err)
+	cookies := reloaded.Cookies(target)
+	assert.Equal(t, 1, len(cookies))
+	assert.Equal(t, "valid", cookies[0].Name)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NilError(t, err)
+	var stored []storedCookie
+	assert.NilError(t, json.Unmarshal(data, &stored))
+	for _, entry := range stored {
+		assert.Equal(t, 0, entry.Cookie.MaxAge)
+		assert.Assert(t, !entry.Cookie.Expires.IsZero())
+	}
+}
+
+func TestNewFileCookieJar_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("not json"), 0600))
+
+	_, err := NewFileCookieJar(path)
+	assert.ErrorContains(t, err, "reading cookie jar")
+}
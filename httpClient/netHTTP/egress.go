@@ -0,0 +1,238 @@
+package nethttp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Logger is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// EgressError is returned for requests the egress policy doesn't let through.
+type EgressError struct {
+	Host string
+	// IP is the address the host resolved to, empty if the request was stopped by its host name alone
+	IP     string
+	Reason string
+}
+
+func (e *EgressError) Error() string {
+	if e.IP != "" {
+		return fmt.Sprintf("egress to %s (%s) denied: %s", e.Host, e.IP, e.Reason)
+	}
+	return fmt.Sprintf("egress to %s denied: %s", e.Host, e.Reason)
+}
+
+// EgressPolicy restricts where the client may connect to. Entries are host names like "example.com",
+// wildcards like "*.example.com" matching all subdomains, IPs or CIDR ranges like "10.0.0.0/8".
+type EgressPolicy struct {
+	// Allow, if not empty, lists the only hosts requests may go to, by name or by address
+	Allow []string
+	// Deny lists hosts requests must never go to, it wins over Allow
+	Deny []string
+	// AllowPrivate lets requests reach loopback, link-local and private addresses without listing their range in Allow
+	AllowPrivate bool
+	// Logger gets a line for every denied request, the standard logger is used if it is nil
+	Logger Logger
+}
+
+// privateRanges are blocked unless allowed explicitly, they cover loopback, link-local, private, shared,
+// benchmarking and multicast addresses, and NAT64 addresses, which may embed any of the IPv4 ones
+var privateRanges = mustParseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+	"198.18.0.0/15", "224.0.0.0/4",
+	"::/128", "::1/128", "64:ff9b::/96", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+type hostList struct {
+	names     map[string]bool
+	wildcards []string
+	ranges    []*net.IPNet
+}
+
+type egressPolicy struct {
+	allow        hostList
+	deny         hostList
+	allowPrivate bool
+	logger       Logger
+}
+
+// WithEgressPolicy checks every request against policy. The host name of the URL is checked before the request
+// is sent and on every redirect, the address it resolved to right before connecting, so a name can't be made to
+// point to an internal address later on. Loopback, link-local and private addresses are denied unless allowed.
+// With a proxy the proxy itself has to pass the policy, requested hosts are then only checked by name.
+func WithEgressPolicy(policy EgressPolicy) Option {
+	return func(o *options) {
+		allow, err := parseHostList(policy.Allow)
+		if err != nil {
+			o.fail(err)
+			return
+		}
+		deny, err := parseHostList(policy.Deny)
+		if err != nil {
+			o.fail(err)
+			return
+		}
+
+		logger := policy.Logger
+		if logger == nil {
+			logger = log.New(os.Stderr, "", log.LstdFlags)
+		}
+		o.egress = &egressPolicy{allow: allow, deny: deny, allowPrivate: policy.AllowPrivate, logger: logger}
+	}
+}
+
+// checkURL stops requests to hosts the policy denies by name, or by address if the URL holds an IP
+func (p *egressPolicy) checkURL(target *url.URL) error {
+	host := strings.ToLower(target.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+
+	if p.deny.matchesName(host) {
+		return p.denied(host, nil, "host is denied")
+	}
+	// With ranges in the allowlist the name may still resolve to an allowed address, which is checked on connecting
+	if !p.allow.empty() && !p.allow.matchesName(host) && len(p.allow.ranges) == 0 {
+		return p.denied(host, nil, "host is not allowed")
+	}
+	return nil
+}
+
+// checkIP decides about a connection to ip, made for host
+func (p *egressPolicy) checkIP(host string, ip net.IP) error {
+	if ip == nil {
+		return p.denied(host, nil, "unknown address")
+	}
+	if p.deny.matchesName(host) || p.deny.matchesIP(ip) {
+		return p.denied(host, ip, "host is denied")
+	}
+	if !p.allow.empty() && !p.allow.matchesName(host) && !p.allow.matchesIP(ip) {
+		return p.denied(host, ip, "host is not allowed")
+	}
+	if !p.allowPrivate && inRanges(privateRanges, ip) && !p.allow.matchesIP(ip) {
+		return p.denied(host, ip, "private address")
+	}
+	return nil
+}
+
+func (p *egressPolicy) denied(host string, ip net.IP, reason string) error {
+	err := &EgressError{Host: host, Reason: reason}
+	if ip != nil {
+		err.IP = ip.String()
+	}
+	p.logger.Printf("egress denied host=%q ip=%q reason=%q", err.Host, err.IP, err.Reason)
+	return err
+}
+
+// dialContext checks the address every connection is made to, after the host name was resolved.
+// dialWith makes the actual connection with the checking copy of dialer.
+func (p *egressPolicy) dialContext(dialer *net.Dialer, dialWith func(dialer *net.Dialer) dialFunc) dialFunc {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(host)
+
+		checked := *dialer
+		control := dialer.Control
+		checked.Control = func(network string, address string, conn syscall.RawConn) error {
+			ipAddress, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if err := p.checkIP(host, net.ParseIP(ipAddress)); err != nil {
+				return err
+			}
+			if control != nil {
+				return control(network, address, conn)
+			}
+			return nil
+		}
+		return dialWith(&checked)(ctx, network, address)
+	}
+}
+
+// checkRedirect applies the policy to every redirect target
+func (p *egressPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	return p.checkURL(req.URL)
+}
+
+func parseHostList(entries []string) (hostList, error) {
+	list := hostList{names: map[string]bool{}}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			_, ipRange, err := net.ParseCIDR(entry)
+			if err != nil {
+				return list, fmt.Errorf("invalid egress range %q: %w", entry, err)
+			}
+			list.ranges = append(list.ranges, ipRange)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			list.ranges = append(list.ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		case strings.HasPrefix(entry, "*."):
+			list.wildcards = append(list.wildcards, entry[1:])
+		default:
+			list.names[entry] = true
+		}
+	}
+	return list, nil
+}
+
+func (l hostList) empty() bool {
+	return len(l.names) == 0 && len(l.wildcards) == 0 && len(l.ranges) == 0
+}
+
+func (l hostList) matchesName(host string) bool {
+	if l.names[host] {
+		return true
+	}
+	for _, suffix := range l.wildcards {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l hostList) matchesIP(ip net.IP) bool {
+	return inRanges(l.ranges, ip)
+}
+
+func inRanges(ranges []*net.IPNet, ip net.IP) bool {
+	for _, ipRange := range ranges {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	ranges := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ranges = append(ranges, ipRange)
+	}
+	return ranges
+}
@@ -0,0 +1,149 @@
+package nethttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestNetHttpClient_EgressPolicy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	// The servers listen on 127.0.0.1, "localhost" makes the client resolve the name first
+	byName := func(server *httptest.Server) string {
+		return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	}
+
+	tests := []struct {
+		name       string
+		policy     EgressPolicy
+		url        string
+		wantErr    *EgressError
+		wantLogged bool
+	}{
+		{
+			name:    "Failed--PrivateAddressByDefault",
+			policy:  EgressPolicy{},
+			url:     target.URL,
+			wantErr: &EgressError{Host: "127.0.0.1", IP: "127.0.0.1", Reason: "private address"},
+		},
+		{
+			name:    "Failed--PrivateAddressAfterResolving",
+			policy:  EgressPolicy{Allow: []string{"localhost"}},
+			url:     byName(target),
+			wantErr: &EgressError{Host: "localhost", IP: "127.0.0.1", Reason: "private address"},
+		},
+		{
+			name:   "Successful--PrivateAllowed",
+			policy: EgressPolicy{AllowPrivate: true},
+			url:    byName(target),
+		},
+		{
+			name:   "Successful--RangeAllowed",
+			policy: EgressPolicy{Allow: []string{"127.0.0.0/8"}},
+			url:    target.URL,
+		},
+		{
+			name:    "Failed--HostNotAllowed",
+			policy:  EgressPolicy{Allow: []string{"*.example.com"}, AllowPrivate: true},
+			url:     byName(target),
+			wantErr: &EgressError{Host: "localhost", Reason: "host is not allowed"},
+		},
+		{
+			name:    "Failed--DenyWinsOverAllow",
+			policy:  EgressPolicy{Allow: []string{"127.0.0.0/8"}, Deny: []string{"127.0.0.1"}},
+			url:     target.URL,
+			wantErr: &EgressError{Host: "127.0.0.1", IP: "127.0.0.1", Reason: "host is denied"},
+		},
+		{
+			name:    "Failed--Redirect",
+			policy:  EgressPolicy{Allow: []string{"localhost"}, AllowPrivate: true},
+			url:     byName(redirecting),
+			wantErr: &EgressError{Host: "127.0.0.1", IP: "127.0.0.1", Reason: "host is not allowed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			tt.policy.Logger = logger
+			client, err := NewNetHttpClient(WithEgressPolicy(tt.policy))
+			assert.NilError(t, err)
+
+			resp, err := client.Get(tt.url)
+			if tt.wantErr == nil {
+				assert.NilError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, 0, len(logger.lines))
+				return
+			}
+
+			var egressErr *EgressError
+			assert.Assert(t, errors.As(err, &egressErr), err)
+			assert.Equal(t, tt.wantErr.Host, egressErr.Host)
+			assert.Equal(t, tt.wantErr.Reason, egressErr.Reason)
+			// localhost may resolve to ::1 as well, so only whether the address was checked is compared
+			assert.Equal(t, tt.wantErr.IP != "", egressErr.IP != "")
+			var urlErr *url.Error
+			assert.Assert(t, errors.As(err, &urlErr))
+			assert.Assert(t, len(logger.lines) > 0)
+			assert.Assert(t, strings.Contains(logger.lines[0], tt.wantErr.Reason), logger.lines[0])
+		})
+	}
+}
+
+func TestEgressPolicy_PrivateRanges(t *testing.T) {
+	o := newOptions()
+	WithEgressPolicy(EgressPolicy{Logger: &recordingLogger{}})(o)
+
+	for ip, wantDenied := range map[string]bool{
+		"10.1.2.3":           true,
+		"127.0.0.1":          true,
+		"169.254.169.254":    true,
+		"198.18.0.1":         true,
+		"198.19.255.255":     true,
+		"224.0.0.251":        true,
+		"239.255.255.250":    true,
+		"::1":                true,
+		"64:ff9b::a9fe:a9fe": true,
+		"fd00::1":            true,
+		"ff02::1":            true,
+		"8.8.8.8":            false,
+		"198.20.0.1":         false,
+		"2001:4860::8888":    false,
+	} {
+		err := o.egress.checkIP("test.url.com", net.ParseIP(ip))
+		assert.Equal(t, wantDenied, err != nil, ip)
+	}
+}
+
+func TestNewNetHttpClient_InvalidEgressPolicy(t *testing.T) {
+	_, err := NewNetHttpClient(WithEgressPolicy(EgressPolicy{Deny: []string{"10.0.0.0/33"}}))
+	assert.ErrorContains(t, err, "invalid egress range")
+}
@@ -2,43 +2,182 @@ package nethttp
 
 import (
 	"bytes"
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"golang.org/x/net/http2"
 )
 
 type netHttpClient struct {
-	Client *http.Client
+	Client      *http.Client
+	egress      *egressPolicy
+	unixSockets map[string]string
+	h2c         *http2.Transport
+
+	mutex    sync.Mutex
+	closed   bool
+	nextID   uint64
+	inFlight map[uint64]context.CancelFunc
+	// drained is closed once the last in-flight request finished after Shutdown was called
+	drained chan struct{}
 }
 
-func NewNetHttpClient() httpclient.HttpClient {
-	return &netHttpClient{
-		&http.Client{},
+// NewNetHttpClient returns an HttpClient backed by net/http, configured by options.
+// It fails if an option can't be applied, e.g. because a certificate file is missing.
+func NewNetHttpClient(options ...Option) (httpclient.HttpClient, error) {
+	o := newOptions()
+	for _, option := range options {
+		option(o)
+	}
+	if o.err != nil {
+		return nil, o.err
 	}
+	dial := o.dialWith(o.dialer)
+	if o.egress != nil {
+		dial = o.egress.dialContext(o.dialer, o.dialWith)
+		o.redirectChecks = append(o.redirectChecks, o.egress.checkRedirect)
+	}
+	h2c := o.setupTransports(dial)
+
+	return &netHttpClient{
+		Client: &http.Client{
+			Transport:     o.transport,
+			Timeout:       o.timeout,
+			CheckRedirect: o.checkRedirect(),
+			Jar:           o.jar,
+		},
+		egress:      o.egress,
+		unixSockets: o.unixSockets,
+		h2c:         h2c,
+		inFlight:    map[uint64]context.CancelFunc{},
+		drained:     make(chan struct{}),
+	}, nil
 }
 
 func (c *netHttpClient) Get(url string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return c.GetWithContext(context.Background(), url)
+}
+
+func (c *netHttpClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.PostWithContext(context.Background(), url, contentType, body)
+}
+
+func (c *netHttpClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.Client.Do(req)
+	return c.Do(req)
 }
 
-func (c *netHttpClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+func (c *netHttpClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
 	bodyReader := bytes.NewReader(body)
-	req, err := http.NewRequest(http.MethodPost, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", contentType)
 
-	return c.Client.Do(req)
+	return c.Do(req)
 }
 
-func (c *netHttpClient) Shutdown() {
+// Do sends req. The request counts as in flight for Shutdown until its response body is closed.
+func (c *netHttpClient) Do(req *http.Request) (*http.Response, error) {
+	// Unix sockets don't leave the machine, so they are no egress
+	_, isSocket := c.unixSockets[strings.ToLower(req.URL.Hostname())]
+	if c.egress != nil && !isSocket {
+		if err := c.egress.checkURL(req.URL); err != nil {
+			// Wrapped like the errors of http.Client, so it doesn't matter at which point the policy stepped in
+			return nil, &url.Error{Op: urlErrorOp(req.Method), URL: req.URL.String(), Err: err}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	id, err := c.track(cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := c.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		c.untrack(id)
+		return nil, err
+	}
+
+	resp.Body = httpclient.OnClose(resp.Body, func() { c.untrack(id) })
+	return resp, nil
+}
+
+func (c *netHttpClient) Shutdown(ctx context.Context) error {
+	c.mutex.Lock()
+	if !c.closed {
+		c.closed = true
+		if len(c.inFlight) == 0 {
+			close(c.drained)
+		}
+	}
+	c.mutex.Unlock()
+
+	var err error
+	select {
+	case <-c.drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		// Out of time, cancel whatever is still running
+		c.mutex.Lock()
+		for _, cancel := range c.inFlight {
+			cancel()
+		}
+		c.mutex.Unlock()
+	}
+
 	// Closing Idle Connections
 	c.Client.CloseIdleConnections()
+	c.h2c.CloseIdleConnections()
+	return err
+}
+
+func (c *netHttpClient) track(cancel context.CancelFunc) (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return 0, httpclient.ErrShutdown
+	}
+
+	c.nextID++
+	c.inFlight[c.nextID] = cancel
+	return c.nextID, nil
+}
+
+func (c *netHttpClient) untrack(id uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cancel, ok := c.inFlight[id]
+	if !ok {
+		return
+	}
+	cancel()
+	delete(c.inFlight, id)
+
+	if c.closed && len(c.inFlight) == 0 {
+		close(c.drained)
+	}
+}
+
+// urlErrorOp turns a method into the Op of a url.Error the way http.Client does, e.g. "Get"
+func urlErrorOp(method string) string {
+	if method == "" {
+		return "Get"
+	}
+	return method[:1] + strings.ToLower(method[1:])
 }
@@ -0,0 +1,146 @@
+package nethttp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"github.com/spf13/viper"
+	"gotest.tools/assert"
+)
+
+func TestNetHttpClient_ShutdownDrains(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	client, _ := NewNetHttpClient()
+	result := make(chan string)
+	go func() {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		result <- string(body)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NilError(t, client.Shutdown(ctx))
+	assert.Equal(t, "done", <-result)
+
+	_, err := client.Get(server.URL)
+	assert.Assert(t, errors.Is(err, httpclient.ErrShutdown))
+}
+
+func TestNetHttpClient_ShutdownCancels(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, _ := NewNetHttpClient()
+	result := make(chan error)
+	go func() {
+		_, err := client.Get(server.URL)
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Assert(t, errors.Is(client.Shutdown(ctx), context.DeadlineExceeded))
+	assert.Assert(t, errors.Is(<-result, context.Canceled))
+}
+
+func TestNetHttpClient_Options(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy gets the absolute URL of the target
+		proxied <- r.URL.String()
+	}))
+	defer proxy.Close()
+
+	t.Run("Timeout", func(t *testing.T) {
+		client, _ := NewNetHttpClient(WithTimeout(20 * time.Millisecond))
+		_, err := client.Get(slow.URL)
+		assert.ErrorContains(t, err, "Client.Timeout exceeded")
+	})
+
+	t.Run("Response-Header-Timeout", func(t *testing.T) {
+		client, _ := NewNetHttpClient(WithResponseHeaderTimeout(20 * time.Millisecond))
+		_, err := client.Get(slow.URL)
+		assert.ErrorContains(t, err, "timeout awaiting response headers")
+	})
+
+	t.Run("Proxy", func(t *testing.T) {
+		client, _ := NewNetHttpClient(WithProxy(proxy.URL, nil))
+		resp, err := client.Get("http://test.url.com/path")
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "http://test.url.com/path", <-proxied)
+	})
+
+	t.Run("No-Proxy", func(t *testing.T) {
+		o := newOptions()
+		WithProxy(proxy.URL, []string{".internal.url.com"})(o)
+
+		for target, wantProxy := range map[string]bool{
+			"http://test.url.com":         true,
+			"http://api.internal.url.com": false,
+		} {
+			req, _ := http.NewRequest(http.MethodGet, target, nil)
+			proxyURL, err := o.transport.Proxy(req)
+			assert.NilError(t, err)
+			assert.Equal(t, wantProxy, proxyURL != nil, target)
+		}
+	})
+
+	t.Run("Environment-Proxy", func(t *testing.T) {
+		// HTTP_PROXY in the environment is meant for plain HTTP only, it must not end up as the proxy of the client
+		t.Setenv("HTTP_PROXY", proxy.URL)
+		t.Setenv("HTTPS_PROXY", "")
+
+		v := viper.New()
+		v.SetConfigType("env")
+		assert.NilError(t, v.ReadConfig(strings.NewReader("HTTP_CLIENT_PROXY=\nHTTP_PROXY=\n")))
+		v.AutomaticEnv()
+		var config util.InfrastructureConfig
+		assert.NilError(t, v.Unmarshal(&config))
+		assert.Equal(t, "", config.HttpProxy)
+
+		o := newOptions()
+		WithConfig(config)(o)
+		req, _ := http.NewRequest(http.MethodGet, "https://test.url.com", nil)
+		proxyURL, err := o.transport.Proxy(req)
+		assert.NilError(t, err)
+		assert.Assert(t, proxyURL == nil, proxyURL)
+	})
+}
@@ -0,0 +1,227 @@
+package nethttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Option configures the client built by NewNetHttpClient.
+type Option func(o *options)
+
+type options struct {
+	timeout   time.Duration
+	dialer    *net.Dialer
+	transport *http.Transport
+	// pins maps a host to the set of public key pins accepted for it
+	pins map[string]map[string]bool
+	// egress is the policy every request is checked against, nil if there is none
+	egress *egressPolicy
+	// redirects are followed up to maxRedirects, every one of them has to pass the redirectChecks
+	followRedirects bool
+	maxRedirects    int
+	redirectChecks  []func(req *http.Request, via []*http.Request) error
+	jar             http.CookieJar
+	// unixSockets maps host names to the socket their requests are sent to
+	unixSockets map[string]string
+	h2c         bool
+	resolver    Resolver
+	// err is the first problem an option ran into, it is returned by NewNetHttpClient
+	err error
+}
+
+// newOptions starts out with the same settings as http.DefaultTransport
+func newOptions() *options {
+	return &options{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		transport:       http.DefaultTransport.(*http.Transport).Clone(),
+		followRedirects: true,
+		maxRedirects:    10,
+	}
+}
+
+func (o *options) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// checkRedirect combines the redirect checks, nil keeps the default policy of net/http
+func (o *options) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	if o.followRedirects && o.maxRedirects == 10 && len(o.redirectChecks) == 0 {
+		return nil
+	}
+
+	follow, max, checks := o.followRedirects, o.maxRedirects, o.redirectChecks
+	return func(req *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		for _, check := range checks {
+			if err := check(req, via); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithConfig applies every transport setting that is set in config.
+func WithConfig(config util.InfrastructureConfig) Option {
+	return func(o *options) {
+		if config.HttpTimeout > 0 {
+			WithTimeout(config.HttpTimeout)(o)
+		}
+		if config.HttpDialTimeout > 0 {
+			WithDialTimeout(config.HttpDialTimeout)(o)
+		}
+		if config.HttpTLSHandshakeTimeout > 0 {
+			WithTLSHandshakeTimeout(config.HttpTLSHandshakeTimeout)(o)
+		}
+		if config.HttpResponseHeaderTimeout > 0 {
+			WithResponseHeaderTimeout(config.HttpResponseHeaderTimeout)(o)
+		}
+		if config.HttpIdleConnTimeout > 0 {
+			WithIdleConnTimeout(config.HttpIdleConnTimeout)(o)
+		}
+		if config.HttpKeepAlive != 0 {
+			WithKeepAlive(config.HttpKeepAlive)(o)
+		}
+		if config.HttpDisableKeepAlives {
+			WithoutKeepAlives()(o)
+		}
+		if config.HttpMaxIdleConns > 0 || config.HttpMaxIdleConnsPerHost > 0 || config.HttpMaxConnsPerHost > 0 {
+			WithConnectionPool(config.HttpMaxIdleConns, config.HttpMaxIdleConnsPerHost, config.HttpMaxConnsPerHost)(o)
+		}
+		if config.HttpProxy != "" {
+			WithProxy(config.HttpProxy, config.HttpNoProxy)(o)
+		}
+		if config.HttpDisableHTTP2 {
+			WithHTTP2(false)(o)
+		}
+		if config.HttpEgressPolicy || len(config.HttpEgressAllow) > 0 || len(config.HttpEgressDeny) > 0 {
+			WithEgressPolicy(EgressPolicy{
+				Allow:        config.HttpEgressAllow,
+				Deny:         config.HttpEgressDeny,
+				AllowPrivate: config.HttpEgressAllowPrivate,
+			})(o)
+		}
+		withRedirectConfig(config.HttpMaxRedirects, config.HttpDisableRedirects, config.HttpRedirectAuth,
+			config.HttpRedirectForbidDowngrade)(o)
+		withCookieJarConfig(config.HttpCookieJar, config.HttpCookieJarFile)(o)
+		withResolverConfig(config.HttpDNSCache, config.HttpDNSServer, config.HttpDNSHosts, config.HttpDNSCacheTTL)(o)
+		withUnixSocketConfig(config.HttpUnixSockets)(o)
+		if config.HttpH2C {
+			WithH2C()(o)
+		}
+		withTLSConfig(config.HttpTLSCAFiles, config.HttpTLSClientCert, config.HttpTLSClientKey,
+			config.HttpTLSMinVersion, config.HttpTLSCipherSuites, config.HttpTLSPins)(o)
+	}
+}
+
+// WithTimeout limits the whole request, from dialing until the response body is read.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithDialTimeout limits how long establishing a connection may take.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.dialer.Timeout = timeout
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period of new connections, a negative period turns keep-alive probes off.
+func WithKeepAlive(period time.Duration) Option {
+	return func(o *options) {
+		o.dialer.KeepAlive = period
+	}
+}
+
+// WithTLSHandshakeTimeout limits how long the TLS handshake may take.
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.transport.TLSHandshakeTimeout = timeout
+	}
+}
+
+// WithResponseHeaderTimeout limits how long to wait for the response headers once the request is written.
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.transport.ResponseHeaderTimeout = timeout
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept in the pool.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.transport.IdleConnTimeout = timeout
+	}
+}
+
+// WithoutKeepAlives uses every connection for a single request only.
+func WithoutKeepAlives() Option {
+	return func(o *options) {
+		o.transport.DisableKeepAlives = true
+	}
+}
+
+// WithConnectionPool sizes the connection pool, zero values leave the respective setting unchanged.
+func WithConnectionPool(maxIdleConns int, maxIdleConnsPerHost int, maxConnsPerHost int) Option {
+	return func(o *options) {
+		if maxIdleConns > 0 {
+			o.transport.MaxIdleConns = maxIdleConns
+		}
+		if maxIdleConnsPerHost > 0 {
+			o.transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		}
+		if maxConnsPerHost > 0 {
+			o.transport.MaxConnsPerHost = maxConnsPerHost
+		}
+	}
+}
+
+// WithProxy sends requests through proxyURL, except the ones to hosts matching noProxy, in place of the proxy
+// taken from the environment. The noProxy entries follow the NO_PROXY conventions, e.g. "example.com",
+// ".example.com", "10.0.0.0/8" or "*".
+func WithProxy(proxyURL string, noProxy []string) Option {
+	return func(o *options) {
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  proxyURL,
+			HTTPSProxy: proxyURL,
+			NoProxy:    strings.Join(noProxy, ","),
+		}).ProxyFunc()
+
+		o.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+}
+
+// WithHTTP2 turns HTTP/2 over TLS on or off, it is on by default.
+func WithHTTP2(enabled bool) Option {
+	return func(o *options) {
+		o.transport.ForceAttemptHTTP2 = enabled
+		if enabled {
+			o.transport.TLSNextProto = nil
+		} else {
+			// A non-nil empty map is how net/http is told not to negotiate HTTP/2
+			o.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+	}
+}
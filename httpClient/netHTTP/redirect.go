@@ -0,0 +1,110 @@
+package nethttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// sensitiveHeaders are the ones net/http drops on redirects to other domains
+var sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// DowngradeError is returned for a redirect from HTTPS to plain HTTP when WithoutRedirectDowngrade is used.
+type DowngradeError struct {
+	From string
+	To   string
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf("refusing redirect from %s to insecure %s", e.From, e.To)
+}
+
+// WithMaxRedirects stops following redirects after max of them with an error, instead of after 10.
+func WithMaxRedirects(max int) Option {
+	return func(o *options) {
+		o.maxRedirects = max
+	}
+}
+
+// WithoutRedirects returns redirect responses to the caller as they are, instead of following them.
+func WithoutRedirects() Option {
+	return func(o *options) {
+		o.followRedirects = false
+	}
+}
+
+// WithCrossHostAuth decides what happens to the Authorization and Cookie headers of a request redirected to
+// another host. Kept, they are sent to every host along the way. Otherwise they are dropped as soon as the host
+// changes, even for subdomains. Without this option the rule of net/http applies, which keeps them for
+// subdomains of the original host only. Cookies of a cookie jar are never affected.
+func WithCrossHostAuth(keep bool) Option {
+	return func(o *options) {
+		if keep {
+			o.redirectChecks = append(o.redirectChecks, keepAuth)
+		} else {
+			o.redirectChecks = append(o.redirectChecks, stripAuth)
+		}
+	}
+}
+
+// WithoutRedirectDowngrade fails redirects from HTTPS to plain HTTP with a DowngradeError.
+func WithoutRedirectDowngrade() Option {
+	return func(o *options) {
+		o.redirectChecks = append(o.redirectChecks, forbidDowngrade)
+	}
+}
+
+func keepAuth(req *http.Request, via []*http.Request) error {
+	original := via[0]
+	for _, name := range sensitiveHeaders {
+		if _, ok := req.Header[name]; !ok && original.Header[name] != nil {
+			req.Header[name] = original.Header[name]
+		}
+	}
+	return nil
+}
+
+func stripAuth(req *http.Request, via []*http.Request) error {
+	if strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		return nil
+	}
+	for _, name := range sensitiveHeaders {
+		req.Header.Del(name)
+	}
+	return nil
+}
+
+func forbidDowngrade(req *http.Request, via []*http.Request) error {
+	previous := via[len(via)-1]
+	if previous.URL.Scheme == "https" && req.URL.Scheme == "http" {
+		return &DowngradeError{From: previous.URL.Redacted(), To: req.URL.Redacted()}
+	}
+	return nil
+}
+
+// withRedirectConfig applies the redirect settings of the config
+func withRedirectConfig(maxRedirects int, disable bool, auth string, forbidDowngrade bool) Option {
+	return func(o *options) {
+		if maxRedirects > 0 {
+			WithMaxRedirects(maxRedirects)(o)
+		}
+		if disable {
+			WithoutRedirects()(o)
+		}
+
+		switch strings.ToLower(auth) {
+		case "":
+		case "keep":
+			WithCrossHostAuth(true)(o)
+		case "strip":
+			WithCrossHostAuth(false)(o)
+		default:
+			o.fail(fmt.Errorf("unknown redirect auth policy %q, expected keep or strip", auth))
+			return
+		}
+
+		if forbidDowngrade {
+			WithoutRedirectDowngrade()(o)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package nethttp
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestNetHttpClient_Redirects(t *testing.T) {
+	// echo answers with the Authorization header it got
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer echo.Close()
+	otherHost := strings.Replace(echo.URL, "127.0.0.1", "localhost", 1)
+
+	// /hops/N redirects N more times before ending up at the echo server on another host
+	var redirecting *httptest.Server
+	redirecting = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hops/3":
+			http.Redirect(w, r, "/hops/2", http.StatusFound)
+		case "/hops/2":
+			http.Redirect(w, r, "/hops/1", http.StatusFound)
+		case "/hops/1":
+			http.Redirect(w, r, otherHost, http.StatusFound)
+		case "/same-host":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/echo":
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer redirecting.Close()
+
+	tests := []struct {
+		name       string
+		options    []Option
+		path       string
+		wantStatus int
+		wantBody   string
+		wantHeader string
+		wantErr    string
+	}{
+		{
+			name:       "Successful--DefaultDropsAuthForOtherHost",
+			path:       "/hops/3",
+			wantStatus: http.StatusOK,
+			wantBody:   "",
+		},
+		{
+			name:       "Successful--KeepAuth",
+			options:    []Option{WithCrossHostAuth(true)},
+			path:       "/hops/3",
+			wantStatus: http.StatusOK,
+			wantBody:   "Bearer token",
+		},
+		{
+			name:       "Successful--StripAuthKeepsItOnTheSameHost",
+			options:    []Option{WithCrossHostAuth(false)},
+			path:       "/same-host",
+			wantStatus: http.StatusOK,
+			wantBody:   "Bearer token",
+		},
+		{
+			name:    "Failed--TooManyRedirects",
+			options: []Option{WithMaxRedirects(2)},
+			path:    "/hops/3",
+			wantErr: "stopped after 2 redirects",
+		},
+		{
+			name:       "Successful--RedirectsDisabled",
+			options:    []Option{WithoutRedirects()},
+			path:       "/hops/3",
+			wantStatus: http.StatusFound,
+			wantHeader: "/hops/2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewNetHttpClient(tt.options...)
+			assert.NilError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, redirecting.URL+tt.path, nil)
+			assert.NilError(t, err)
+			req.Header.Set("Authorization", "Bearer token")
+			resp, err := client.Do(req)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantHeader, resp.Header.Get("Location"))
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestNetHttpClient_RedirectDowngrade(t *testing.T) {
+	insecure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("insecure"))
+	}))
+	defer insecure.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, insecure.URL, http.StatusFound)
+	}))
+	defer secure.Close()
+
+	for _, forbid := range []bool{false, true} {
+		var options []Option
+		if forbid {
+			options = append(options, WithoutRedirectDowngrade())
+		}
+		client, err := NewNetHttpClient(options...)
+		assert.NilError(t, err)
+		client.(*netHttpClient).Client.Transport.(*http.Transport).TLSClientConfig = secure.Client().Transport.(*http.Transport).TLSClientConfig
+
+		resp, err := client.Get(secure.URL)
+		if !forbid {
+			assert.NilError(t, err)
+			resp.Body.Close()
+			continue
+		}
+
+		var downgrade *DowngradeError
+		assert.Assert(t, errors.As(err, &downgrade))
+		assert.Equal(t, secure.URL, downgrade.From)
+		assert.Equal(t, insecure.URL, downgrade.To)
+	}
+}
+
+func TestNewNetHttpClient_InvalidRedirectConfig(t *testing.T) {
+	_, err := NewNetHttpClient(withRedirectConfig(0, false, "forward", false))
+	assert.Error(t, err, `unknown redirect auth policy "forward", expected keep or strip`)
+}
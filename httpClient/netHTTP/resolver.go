@@ -0,0 +1,402 @@
+package nethttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Resolver looks up the addresses of a host name before the client connects to it.
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// ResolverConfig configures the resolver returned by NewCachingResolver.
+type ResolverConfig struct {
+	// Server is the "host:port" of the DNS server to ask, the system resolver is used if it is empty
+	Server string
+	// Hosts maps host names to fixed addresses, like /etc/hosts does, they are never looked up
+	Hosts map[string][]net.IP
+	// TTL is how long answers of the system resolver are cached, as it doesn't tell their TTL, 30 seconds if not set
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+type cachingResolver struct {
+	server string
+	hosts  map[string][]net.IP
+	ttl    time.Duration
+	dialer *net.Dialer
+	now    func() time.Time
+
+	mutex sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewCachingResolver returns a Resolver that caches the answers of the DNS server in config.Server for as long as
+// their TTL says, and answers config.Hosts right away.
+func NewCachingResolver(config ResolverConfig) Resolver {
+	r := &cachingResolver{
+		server: config.Server,
+		hosts:  map[string][]net.IP{},
+		ttl:    config.TTL,
+		dialer: &net.Dialer{Timeout: 5 * time.Second},
+		now:    time.Now,
+		cache:  map[string]cacheEntry{},
+	}
+	for host, ips := range config.Hosts {
+		r.hosts[strings.ToLower(host)] = ips
+	}
+	if r.ttl <= 0 {
+		r.ttl = 30 * time.Second
+	}
+	return r
+}
+
+// WithResolver looks up host names with resolver, e.g. one from NewCachingResolver, instead of the system resolver.
+// The addresses are dialed one after the other until a connection succeeds.
+func WithResolver(resolver Resolver) Option {
+	return func(o *options) {
+		o.resolver = resolver
+	}
+}
+
+// dialWith returns a dial function using dialer, which resolves host names with the resolver of the options first
+func (o *options) dialWith(dialer *net.Dialer) dialFunc {
+	resolver := o.resolver
+	if resolver == nil {
+		return dialer.DialContext
+	}
+
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		ips, err := resolver.LookupIP(ctx, host)
+		if err == nil && len(ips) == 0 {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
+
+func (r *cachingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ips, ok := r.hosts[host]; ok {
+		return ips, nil
+	}
+
+	r.mutex.Lock()
+	entry, ok := r.cache[host]
+	if ok && !r.now().Before(entry.expires) {
+		delete(r.cache, host)
+		ok = false
+	}
+	r.mutex.Unlock()
+	if ok {
+		return entry.ips, nil
+	}
+
+	ips, ttl, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		r.store(host, ips, ttl)
+	}
+	return ips, nil
+}
+
+// store caches ips and drops the entries that expired meanwhile, so hosts asked for only once don't pile up
+func (r *cachingResolver) store(host string, ips []net.IP, ttl time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	for cached, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, cached)
+		}
+	}
+	r.cache[host] = cacheEntry{ips: ips, expires: now.Add(ttl)}
+}
+
+// lookup returns the addresses of host, IPv4 first, and how long they may be cached
+func (r *cachingResolver) lookup(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if r.server == "" {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, r.ttl, nil
+	}
+
+	var ips []net.IP
+	var ttl time.Duration
+	found := false
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answer, answerTTL, err := r.query(ctx, host, qtype)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(answer) > 0 && (!found || answerTTL < ttl) {
+			ttl = answerTTL
+		}
+		found = found || len(answer) > 0
+		ips = append(ips, answer...)
+	}
+
+	if len(ips) == 0 {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: r.server, IsNotFound: true}
+	}
+	return ips, ttl, nil
+}
+
+// query asks the server for the records of type qtype, over UDP and again over TCP if the answer was truncated
+func (r *cachingResolver) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	// An unpredictable ID makes it harder to slip in a forged response
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, 0, err
+	}
+	question := dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}
+	message := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{question},
+	}
+	packet, err := message.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response, err := r.exchange(ctx, "udp", message.Header.ID, packet)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips, ttl, truncated, err := r.parse(host, message.Header.ID, question, response)
+	if truncated {
+		if response, err = r.exchange(ctx, "tcp", message.Header.ID, packet); err != nil {
+			return nil, 0, err
+		}
+		ips, ttl, _, err = r.parse(host, message.Header.ID, question, response)
+	}
+	return ips, ttl, err
+}
+
+// exchange sends packet to the server and returns its response. Over UDP, datagrams with another ID than id are
+// skipped, they are late answers to earlier queries or forged ones, and the real answer may still come.
+func (r *cachingResolver) exchange(ctx context.Context, network string, id uint16, packet []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, network, r.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	if network == "udp" {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+		response := make([]byte, 512)
+		for {
+			n, err := conn.Read(response)
+			if err != nil {
+				return nil, err
+			}
+			if n >= 2 && binary.BigEndian.Uint16(response) == id {
+				return response[:n], nil
+			}
+		}
+	}
+
+	// Over TCP every message is preceded by its length
+	framed := make([]byte, 2+len(packet))
+	binary.BigEndian.PutUint16(framed, uint16(len(packet)))
+	copy(framed[2:], packet)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// parse collects the addresses of a response to question. Only records for the asked name, or a name it is a
+// CNAME of, are used, and the TTL is the lowest one of them, CNAMEs included.
+func (r *cachingResolver) parse(host string, id uint16, question dnsmessage.Question, response []byte) ([]net.IP, time.Duration, bool, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if header.ID != id || !header.Response {
+		return nil, 0, false, &net.DNSError{Err: "unexpected response", Name: host, Server: r.server}
+	}
+	if header.Truncated {
+		return nil, 0, true, nil
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, false, &net.DNSError{Err: "no such host", Name: host, Server: r.server, IsNotFound: true}
+	default:
+		return nil, 0, false, &net.DNSError{Err: fmt.Sprintf("server answered %s", header.RCode), Name: host, Server: r.server, IsTemporary: true}
+	}
+
+	questions, err := parser.AllQuestions()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(questions) != 1 || questions[0].Type != question.Type || !sameName(questions[0].Name, question.Name) {
+		return nil, 0, false, &net.DNSError{Err: "response to another question", Name: host, Server: r.server}
+	}
+
+	names := []dnsmessage.Name{question.Name}
+	var ips []net.IP
+	var ttl time.Duration
+	found := false
+	for {
+		answer, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		var ip net.IP
+		used := true
+		switch {
+		case !answersFor(answer.Name, names):
+			used = false
+			err = parser.SkipAnswer()
+		case answer.Type == dnsmessage.TypeCNAME:
+			var resource dnsmessage.CNAMEResource
+			resource, err = parser.CNAMEResource()
+			names = append(names, resource.CNAME)
+		case answer.Type == dnsmessage.TypeA:
+			var resource dnsmessage.AResource
+			resource, err = parser.AResource()
+			ip = net.IP(resource.A[:])
+		case answer.Type == dnsmessage.TypeAAAA:
+			var resource dnsmessage.AAAAResource
+			resource, err = parser.AAAAResource()
+			ip = net.IP(resource.AAAA[:])
+		default:
+			used = false
+			err = parser.SkipAnswer()
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if !used {
+			continue
+		}
+
+		if answerTTL := time.Duration(answer.TTL) * time.Second; !found || answerTTL < ttl {
+			ttl = answerTTL
+		}
+		found = true
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, ttl, false, nil
+}
+
+func answersFor(name dnsmessage.Name, names []dnsmessage.Name) bool {
+	for _, candidate := range names {
+		if sameName(name, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameName compares domain names the DNS way, ignoring case
+func sameName(a dnsmessage.Name, b dnsmessage.Name) bool {
+	return strings.EqualFold(a.String(), b.String())
+}
+
+// withResolverConfig sets up a caching resolver if the config asks for one, hosts are "host=ip" entries
+func withResolverConfig(cache bool, server string, hosts []string, ttl time.Duration) Option {
+	return func(o *options) {
+		if !cache && server == "" && len(hosts) == 0 {
+			return
+		}
+
+		config := ResolverConfig{Server: server, Hosts: map[string][]net.IP{}, TTL: ttl}
+		for _, entry := range hosts {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.SplitN(entry, "=", 2)
+			var ip net.IP
+			if len(parts) == 2 {
+				ip = net.ParseIP(strings.TrimSpace(parts[1]))
+			}
+			if ip == nil || strings.TrimSpace(parts[0]) == "" {
+				o.fail(fmt.Errorf("invalid host override %q, expected host=ip", entry))
+				return
+			}
+			host := strings.ToLower(strings.TrimSpace(parts[0]))
+			config.Hosts[host] = append(config.Hosts[host], ip)
+		}
+		WithResolver(NewCachingResolver(config))(o)
+	}
+}
@@ -0,0 +1,405 @@
+package nethttp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"golang.org/x/net/dns/dnsmessage"
+	"gotest.tools/assert"
+)
+
+// dnsStub answers A queries for its records over UDP and TCP on the same port.
+// Names starting with "tcp." are only answered over TCP, UDP gets a truncated response.
+type dnsStub struct {
+	addr    string
+	records map[string]net.IP
+	ttl     uint32
+	mutex   sync.Mutex
+	queries map[string]int
+	// forge changes a response before it is sent, if set
+	forge func(response *dnsmessage.Message)
+	// stale sends a response with another ID ahead of every UDP response
+	stale bool
+}
+
+// staticResolver answers every host with the same addresses
+type staticResolver []net.IP
+
+func (r staticResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return r, nil
+}
+
+func newDNSStub(t *testing.T, ttl uint32, records map[string]net.IP) *dnsStub {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		packetConn.Close()
+		listener.Close()
+	})
+
+	stub := &dnsStub{addr: packetConn.LocalAddr().String(), records: records, ttl: ttl, queries: map[string]int{}}
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := packetConn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			response := stub.answer(buffer[:n], false)
+			stub.mutex.Lock()
+			stale := stub.stale
+			stub.mutex.Unlock()
+			if stale && len(response) >= 2 {
+				forged := append([]byte{}, response...)
+				binary.BigEndian.PutUint16(forged, binary.BigEndian.Uint16(response)+1)
+				packetConn.WriteTo(forged, addr)
+			}
+			packetConn.WriteTo(response, addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			io.ReadFull(conn, length[:])
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			io.ReadFull(conn, query)
+			response := stub.answer(query, true)
+			binary.BigEndian.PutUint16(length[:], uint16(len(response)))
+			conn.Write(append(length[:], response...))
+			conn.Close()
+		}
+	}()
+	return stub
+}
+
+func (s *dnsStub) answer(query []byte, tcp bool) []byte {
+	var request dnsmessage.Message
+	if err := request.Unpack(query); err != nil || len(request.Questions) != 1 {
+		return nil
+	}
+	question := request.Questions[0]
+	name := strings.TrimSuffix(question.Name.String(), ".")
+
+	s.mutex.Lock()
+	s.queries[name+" "+question.Type.String()]++
+	forge := s.forge
+	s.mutex.Unlock()
+
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: request.ID, Response: true, RecursionAvailable: true},
+		Questions: request.Questions,
+	}
+	ip, ok := s.records[name]
+	switch {
+	case !ok:
+		response.RCode = dnsmessage.RCodeNameError
+	case strings.HasPrefix(name, "tcp.") && !tcp:
+		response.Truncated = true
+	case question.Type == dnsmessage.TypeA:
+		var a [4]byte
+		copy(a[:], ip.To4())
+		response.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: s.ttl},
+			Body:   &dnsmessage.AResource{A: a},
+		}}
+	}
+	if forge != nil {
+		forge(&response)
+	}
+	packet, _ := response.Pack()
+	return packet
+}
+
+func (s *dnsStub) count(query string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.queries[query]
+}
+
+func TestCachingResolver(t *testing.T) {
+	stub := newDNSStub(t, 60, map[string]net.IP{
+		"service.test":     net.ParseIP("10.0.0.1"),
+		"tcp.service.test": net.ParseIP("10.0.0.2"),
+	})
+
+	tests := []struct {
+		name         string
+		config       ResolverConfig
+		host         string
+		wantIPs      []string
+		wantNotFound bool
+		wantQueries  map[string]int
+	}{
+		{
+			name:        "Successful--Server",
+			config:      ResolverConfig{Server: stub.addr},
+			host:        "service.test",
+			wantIPs:     []string{"10.0.0.1"},
+			wantQueries: map[string]int{"service.test TypeA": 1, "service.test TypeAAAA": 1},
+		},
+		{
+			name:        "Successful--TruncatedRetriedOverTCP",
+			config:      ResolverConfig{Server: stub.addr},
+			host:        "TCP.service.test.",
+			wantIPs:     []string{"10.0.0.2"},
+			wantQueries: map[string]int{"tcp.service.test TypeA": 2},
+		},
+		{
+			name:        "Successful--StaticHost",
+			config:      ResolverConfig{Server: stub.addr, Hosts: map[string][]net.IP{"Static.test": {net.ParseIP("10.0.0.3"), net.ParseIP("::1")}}},
+			host:        "static.test",
+			wantIPs:     []string{"10.0.0.3", "::1"},
+			wantQueries: map[string]int{"static.test TypeA": 0},
+		},
+		{
+			name:         "Failed--NotFound",
+			config:       ResolverConfig{Server: stub.addr},
+			host:         "missing.test",
+			wantNotFound: true,
+			wantQueries:  map[string]int{"missing.test TypeA": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub.mutex.Lock()
+			stub.queries = map[string]int{}
+			stub.mutex.Unlock()
+			resolver := NewCachingResolver(tt.config)
+
+			ips, err := resolver.LookupIP(context.Background(), tt.host)
+			if tt.wantNotFound {
+				var dnsErr *net.DNSError
+				assert.Assert(t, errors.As(err, &dnsErr))
+				assert.Assert(t, dnsErr.IsNotFound)
+			} else {
+				assert.NilError(t, err)
+				got := make([]string, 0, len(ips))
+				for _, ip := range ips {
+					got = append(got, ip.String())
+				}
+				assert.DeepEqual(t, tt.wantIPs, got)
+			}
+
+			for query, want := range tt.wantQueries {
+				assert.Equal(t, want, stub.count(query), query)
+			}
+		})
+	}
+}
+
+func TestCachingResolver_TTL(t *testing.T) {
+	stub := newDNSStub(t, 60, map[string]net.IP{"service.test": net.ParseIP("10.0.0.1")})
+	now := time.Now()
+	resolver := NewCachingResolver(ResolverConfig{Server: stub.addr}).(*cachingResolver)
+	resolver.now = func() time.Time { return now }
+
+	lookup := func() {
+		_, err := resolver.LookupIP(context.Background(), "service.test")
+		assert.NilError(t, err)
+	}
+
+	lookup()
+	now = now.Add(59 * time.Second)
+	lookup()
+	assert.Equal(t, 1, stub.count("service.test TypeA"))
+
+	// Once the TTL of the answer ran out the server is asked again
+	now = now.Add(time.Second)
+	lookup()
+	assert.Equal(t, 2, stub.count("service.test TypeA"))
+}
+
+func TestCachingResolver_ZeroTTL(t *testing.T) {
+	stub := newDNSStub(t, 0, map[string]net.IP{"service.test": net.ParseIP("10.0.0.1")})
+	resolver := NewCachingResolver(ResolverConfig{Server: stub.addr})
+
+	for i := 0; i < 2; i++ {
+		_, err := resolver.LookupIP(context.Background(), "service.test")
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, 2, stub.count("service.test TypeA"))
+}
+
+func TestCachingResolver_EvictsExpired(t *testing.T) {
+	stub := newDNSStub(t, 60, map[string]net.IP{"a.test": net.ParseIP("10.0.0.1"), "b.test": net.ParseIP("10.0.0.2")})
+	now := time.Now()
+	resolver := NewCachingResolver(ResolverConfig{Server: stub.addr}).(*cachingResolver)
+	resolver.now = func() time.Time { return now }
+
+	_, err := resolver.LookupIP(context.Background(), "a.test")
+	assert.NilError(t, err)
+	now = now.Add(time.Minute)
+	_, err = resolver.LookupIP(context.Background(), "b.test")
+	assert.NilError(t, err)
+
+	_, ok := resolver.cache["a.test"]
+	assert.Assert(t, !ok)
+	assert.Equal(t, 1, len(resolver.cache))
+}
+
+func TestCachingResolver_SkipsOtherIDs(t *testing.T) {
+	stub := newDNSStub(t, 60, map[string]net.IP{"service.test": net.ParseIP("10.0.0.1")})
+	stub.mutex.Lock()
+	stub.stale = true
+	stub.mutex.Unlock()
+
+	ips, err := NewCachingResolver(ResolverConfig{Server: stub.addr}).LookupIP(context.Background(), "service.test")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(ips))
+	assert.Equal(t, "10.0.0.1", ips[0].String())
+}
+
+func TestCachingResolver_Names(t *testing.T) {
+	aResource := func(name string, ip string) dnsmessage.Resource {
+		var a [4]byte
+		copy(a[:], net.ParseIP(ip).To4())
+		return dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: a},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		forge   func(response *dnsmessage.Message)
+		wantIPs []string
+		wantErr string
+		wantTTL time.Duration
+	}{
+		{
+			name: "Successful--CNAME",
+			forge: func(response *dnsmessage.Message) {
+				response.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("SERVICE.test."), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 10},
+					Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("real.test.")},
+				}, aResource("real.test.", "10.0.0.5")}
+			},
+			wantIPs: []string{"10.0.0.5"},
+			wantTTL: 10 * time.Second,
+		},
+		{
+			name: "Failed--OtherName",
+			forge: func(response *dnsmessage.Message) {
+				response.Answers = []dnsmessage.Resource{aResource("evil.test.", "10.6.6.6")}
+			},
+			wantErr: "no such host",
+		},
+		{
+			name: "Failed--OtherQuestion",
+			forge: func(response *dnsmessage.Message) {
+				response.Questions[0].Name = dnsmessage.MustNewName("evil.test.")
+			},
+			wantErr: "response to another question",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newDNSStub(t, 60, map[string]net.IP{"service.test": net.ParseIP("10.0.0.1")})
+			stub.mutex.Lock()
+			stub.forge = func(response *dnsmessage.Message) {
+				if response.Questions[0].Type == dnsmessage.TypeA {
+					tt.forge(response)
+				}
+			}
+			stub.mutex.Unlock()
+			resolver := NewCachingResolver(ResolverConfig{Server: stub.addr}).(*cachingResolver)
+
+			ips, err := resolver.LookupIP(context.Background(), "service.test")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			got := make([]string, 0, len(ips))
+			for _, ip := range ips {
+				got = append(got, ip.String())
+			}
+			assert.DeepEqual(t, tt.wantIPs, got)
+			assert.Equal(t, tt.wantTTL, resolver.cache["service.test"].expires.Sub(resolver.now()).Round(time.Second))
+		})
+	}
+}
+
+func TestNetHttpClient_Resolver(t *testing.T) {
+	server := httptest.NewServer(describe)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	stub := newDNSStub(t, 60, map[string]net.IP{"service.test": net.ParseIP("127.0.0.1")})
+
+	tests := []struct {
+		name    string
+		options []Option
+		host    string
+		wantErr string
+	}{
+		{
+			name:    "Successful--Server",
+			options: []Option{WithConfig(util.InfrastructureConfig{HttpDNSServer: stub.addr})},
+			host:    "service.test",
+		},
+		{
+			name:    "Successful--StaticHost",
+			options: []Option{WithConfig(util.InfrastructureConfig{HttpDNSHosts: []string{"static.test=127.0.0.1"}})},
+			host:    "static.test",
+		},
+		{
+			name:    "Successful--EgressSeesHostName",
+			options: []Option{WithResolver(NewCachingResolver(ResolverConfig{Server: stub.addr})), WithEgressPolicy(EgressPolicy{Allow: []string{"service.test"}, AllowPrivate: true})},
+			host:    "service.test",
+		},
+		{
+			name:    "Failed--NotFound",
+			options: []Option{WithConfig(util.InfrastructureConfig{HttpDNSServer: stub.addr})},
+			host:    "missing.test",
+			wantErr: "no such host",
+		},
+		{
+			name:    "Failed--NoAddresses",
+			options: []Option{WithResolver(staticResolver{})},
+			host:    "service.test",
+			wantErr: "no such host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewNetHttpClient(tt.options...)
+			assert.NilError(t, err)
+
+			resp, err := client.Get("http://" + tt.host + ":" + port + "/")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			// The Host header keeps the name, only the connection goes to the resolved address
+			assert.Equal(t, "HTTP/1.1 "+tt.host+":"+port+" /", string(body))
+		})
+	}
+}
+
+func TestNewNetHttpClient_InvalidHostOverride(t *testing.T) {
+	_, err := NewNetHttpClient(withResolverConfig(false, "", []string{"static.test=not-an-ip"}, 0))
+	assert.Error(t, err, `invalid host override "static.test=not-an-ip", expected host=ip`)
+}
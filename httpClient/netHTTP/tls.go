@@ -0,0 +1,253 @@
+package nethttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PinError is returned when none of the public keys presented by a host matches one of its pins.
+type PinError struct {
+	Host string
+	// Presented holds the pins of the certificates the host sent, for comparing against the configuration
+	Presented []string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("no pinned public key for host %q, presented %s", e.Host, strings.Join(e.Presented, ", "))
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (o *options) tlsConfig() *tls.Config {
+	if o.transport.TLSClientConfig == nil {
+		o.transport.TLSClientConfig = &tls.Config{}
+	}
+	return o.transport.TLSClientConfig
+}
+
+// WithRootCAFiles trusts the certificates in the given PEM files in addition to the system ones.
+func WithRootCAFiles(paths ...string) Option {
+	return func(o *options) {
+		config := o.tlsConfig()
+		if config.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			config.RootCAs = pool
+		}
+
+		for _, path := range paths {
+			pem, err := ioutil.ReadFile(path)
+			if err != nil {
+				o.fail(fmt.Errorf("reading CA bundle: %w", err))
+				return
+			}
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				o.fail(fmt.Errorf("no certificates found in CA bundle %q", path))
+				return
+			}
+		}
+	}
+}
+
+// WithClientCertificate presents the given key pair to servers asking for a client certificate.
+// The files are checked on every handshake and loaded again once they were replaced.
+func WithClientCertificate(certFile string, keyFile string) Option {
+	return func(o *options) {
+		reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+		if _, err := reloader.certificate(); err != nil {
+			o.fail(fmt.Errorf("loading client certificate: %w", err))
+			return
+		}
+
+		o.tlsConfig().GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	}
+}
+
+// WithMinTLSVersion refuses servers that can't speak at least version, e.g. tls.VersionTLS12.
+func WithMinTLSVersion(version uint16) Option {
+	return func(o *options) {
+		o.tlsConfig().MinVersion = version
+	}
+}
+
+// WithCipherSuites limits the cipher suites offered for TLS 1.2 and below, TLS 1.3 suites are not configurable.
+func WithCipherSuites(suites ...uint16) Option {
+	return func(o *options) {
+		o.tlsConfig().CipherSuites = suites
+	}
+}
+
+// WithPins only accepts connections to host if one of the certificates in the chain has a public key matching
+// one of pins. A pin is the base64 encoded SHA-256 of the DER encoded SubjectPublicKeyInfo, as used by HPKP.
+// Hosts without pins are verified the usual way only. Hosts are matched by the TLS server name, which is
+// not sent for IP addresses, so those can't be pinned.
+func WithPins(host string, pins ...string) Option {
+	return func(o *options) {
+		if o.pins == nil {
+			o.pins = map[string]map[string]bool{}
+			o.tlsConfig().VerifyConnection = o.verifyPins
+		}
+
+		host = strings.ToLower(host)
+		if o.pins[host] == nil {
+			o.pins[host] = map[string]bool{}
+		}
+		for _, pin := range pins {
+			o.pins[host][pin] = true
+		}
+	}
+}
+
+func (o *options) verifyPins(state tls.ConnectionState) error {
+	host := strings.ToLower(state.ServerName)
+	pins, ok := o.pins[host]
+	if !ok {
+		return nil
+	}
+
+	// The server may send any certificates it likes, only the verified chains prove it holds a pinned key.
+	// Without verification only the leaf is known to belong to the server.
+	var certificates []*x509.Certificate
+	for _, chain := range state.VerifiedChains {
+		certificates = append(certificates, chain...)
+	}
+	if len(state.VerifiedChains) == 0 && len(state.PeerCertificates) > 0 {
+		certificates = state.PeerCertificates[:1]
+	}
+
+	for _, certificate := range certificates {
+		if pins[spkiPin(certificate)] {
+			return nil
+		}
+	}
+
+	presented := make([]string, 0, len(state.PeerCertificates))
+	for _, certificate := range state.PeerCertificates {
+		presented = append(presented, spkiPin(certificate))
+	}
+	return &PinError{Host: host, Presented: presented}
+}
+
+func spkiPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// withTLSConfig applies the TLS settings of the config
+func withTLSConfig(caFiles []string, certFile string, keyFile string, minVersion string, cipherSuites []string, pins []string) Option {
+	return func(o *options) {
+		if len(caFiles) > 0 {
+			WithRootCAFiles(caFiles...)(o)
+		}
+
+		if certFile != "" || keyFile != "" {
+			WithClientCertificate(certFile, keyFile)(o)
+		}
+
+		if minVersion != "" {
+			version, ok := tlsVersions[minVersion]
+			if !ok {
+				o.fail(fmt.Errorf("unknown TLS version %q", minVersion))
+				return
+			}
+			WithMinTLSVersion(version)(o)
+		}
+
+		if len(cipherSuites) > 0 {
+			ids := make([]uint16, 0, len(cipherSuites))
+			for _, name := range cipherSuites {
+				id, ok := cipherSuiteID(strings.TrimSpace(name))
+				if !ok {
+					o.fail(fmt.Errorf("unknown cipher suite %q", name))
+					return
+				}
+				ids = append(ids, id)
+			}
+			WithCipherSuites(ids...)(o)
+		}
+
+		for _, entry := range pins {
+			parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				o.fail(fmt.Errorf("invalid pin %q, expected host=pin", entry))
+				return
+			}
+			// The pin itself is base64 and may end in "=", so only the first one separates the host
+			WithPins(parts[0], parts[1])(o)
+		}
+	}
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// certificateReloader loads a key pair again whenever one of its files was modified
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	loaded      *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func (r *certificateReloader) certificate() (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+
+	if r.loaded != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.loaded, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// The files may be caught in the middle of being replaced, try again on the next handshake
+		return r.fallback(err)
+	}
+
+	r.loaded = &certificate
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return r.loaded, nil
+}
+
+// fallback keeps using the last good certificate while the files can't be loaded
+func (r *certificateReloader) fallback(err error) (*tls.Certificate, error) {
+	if r.loaded != nil {
+		return r.loaded, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,245 @@
+package nethttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"gotest.tools/assert"
+)
+
+func TestNetHttpClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := server.Certificate()
+	err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}), 0600)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name        string
+		options     []Option
+		wantPin     bool
+		wantErr     bool
+		wantVersion uint16
+	}{
+		{
+			name:    "Failed--Unknown-CA",
+			wantErr: true,
+		},
+		{
+			name:    "Successful--CA-File",
+			options: []Option{WithRootCAFiles(caFile)},
+		},
+		{
+			name:    "Successful--Matching-Pin",
+			options: []Option{WithRootCAFiles(caFile), WithPins("example.com", "b3RoZXI=", spkiPin(certificate))},
+		},
+		{
+			name:    "Successful--Other-Host-Pinned",
+			options: []Option{WithRootCAFiles(caFile), WithPins("test.url.com", "b3RoZXI=")},
+		},
+		{
+			name:    "Failed--Pin-Mismatch",
+			options: []Option{WithRootCAFiles(caFile), WithPins("example.com", "b3RoZXI=")},
+			wantPin: true,
+			wantErr: true,
+		},
+		{
+			name:        "Successful--Min-Version",
+			options:     []Option{WithRootCAFiles(caFile), WithMinTLSVersion(tls.VersionTLS13), WithCipherSuites(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)},
+			wantVersion: tls.VersionTLS13,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewNetHttpClient(tt.options...)
+			assert.NilError(t, err)
+
+			// The test certificate is issued for example.com, which has to be sent as server name for pinning
+			dialServer(client, server)
+
+			resp, err := client.Get("https://example.com")
+			if err == nil {
+				resp.Body.Close()
+				if tt.wantVersion != 0 {
+					assert.Equal(t, tt.wantVersion, resp.TLS.Version)
+				}
+			}
+
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			var pinErr *PinError
+			assert.Equal(t, tt.wantPin, errors.As(err, &pinErr))
+		})
+	}
+}
+
+// testCertificate is a key pair for example.com and 127.0.0.1, signed by parent or by itself if parent is nil
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, commonName string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NilError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+// write stores the certificate and key as PEM files and returns their paths
+func (c *testCertificate) write(t *testing.T, dir string) (string, string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	assert.NilError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NilError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.certificate.Raw}), 0600))
+	assert.NilError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func (c *testCertificate) tlsCertificate(chain ...*x509.Certificate) tls.Certificate {
+	certificate := tls.Certificate{Certificate: [][]byte{c.certificate.Raw}, PrivateKey: c.key, Leaf: c.certificate}
+	for _, extra := range chain {
+		certificate.Certificate = append(certificate.Certificate, extra.Raw)
+	}
+	return certificate
+}
+
+// dialServer sends all connections of client to server, so requests can use a name of its certificate
+func dialServer(client httpclient.HttpClient, server *httptest.Server) {
+	client.(*netHttpClient).Client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return net.Dial(network, server.Listener.Addr().String())
+	}
+}
+
+func TestNetHttpClient_PinNotInVerifiedChain(t *testing.T) {
+	serverCertificate := newTestCertificate(t, "server", nil)
+	pinned := newTestCertificate(t, "pinned", nil)
+
+	// The server appends the pinned certificate to its chain, but it is not part of what gets verified
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCertificate.tlsCertificate(pinned.certificate)}}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile, _ := serverCertificate.write(t, t.TempDir())
+	client, err := NewNetHttpClient(WithRootCAFiles(caFile), WithPins("example.com", spkiPin(pinned.certificate)))
+	assert.NilError(t, err)
+	dialServer(client, server)
+
+	_, err = client.Get("https://example.com")
+	var pinErr *PinError
+	assert.Assert(t, errors.As(err, &pinErr), err)
+	assert.DeepEqual(t, []string{spkiPin(serverCertificate.certificate), spkiPin(pinned.certificate)}, pinErr.Presented)
+}
+
+func TestNetHttpClient_ClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	serverCertificate := newTestCertificate(t, "server", ca)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NilError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}), 0600))
+	certFile, keyFile := newTestCertificate(t, "first", ca).write(t, dir)
+
+	client, err := NewNetHttpClient(WithRootCAFiles(caFile), WithClientCertificate(certFile, keyFile), WithoutKeepAlives())
+	assert.NilError(t, err)
+	dialServer(client, server)
+
+	get := func() string {
+		resp, err := client.Get("https://example.com")
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		return string(body)
+	}
+	assert.Equal(t, "first", get())
+
+	// Replacing the key pair on disk is picked up by the next handshake
+	newTestCertificate(t, "second", ca).write(t, dir)
+	later := time.Now().Add(time.Minute)
+	assert.NilError(t, os.Chtimes(certFile, later, later))
+	assert.NilError(t, os.Chtimes(keyFile, later, later))
+	assert.Equal(t, "second", get())
+
+	// Without a certificate the server refuses the handshake
+	withoutCertificate, err := NewNetHttpClient(WithRootCAFiles(caFile))
+	assert.NilError(t, err)
+	dialServer(withoutCertificate, server)
+	_, err = withoutCertificate.Get("https://example.com")
+	assert.Assert(t, err != nil)
+}
+
+func TestNewNetHttpClient_InvalidTLS(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	for _, option := range []Option{
+		WithRootCAFiles(missing),
+		WithClientCertificate(missing, missing),
+		withTLSConfig(nil, "", "", "1.4", nil, nil),
+		withTLSConfig(nil, "", "", "", []string{"TLS_NOT_A_SUITE"}, nil),
+		withTLSConfig(nil, "", "", "", nil, []string{"test.url.com"}),
+	} {
+		_, err := NewNetHttpClient(option)
+		assert.Assert(t, err != nil)
+	}
+}
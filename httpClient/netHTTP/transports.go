@@ -0,0 +1,126 @@
+package nethttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// dialFunc is the signature of http.Transport.DialContext
+type dialFunc func(ctx context.Context, network string, address string) (net.Conn, error)
+
+// WithUnixSocket sends requests for host to the Unix domain socket at socketPath instead of over TCP. Such requests
+// either use the host in a plain HTTP URL, like "http://sidecar/status", or the "unix" scheme, like
+// "unix://sidecar/status", which fails if no socket is configured for the host. They are never proxied and not
+// subject to an egress policy.
+func WithUnixSocket(host string, socketPath string) Option {
+	return func(o *options) {
+		if o.unixSockets == nil {
+			o.unixSockets = map[string]string{}
+		}
+		o.unixSockets[strings.ToLower(host)] = socketPath
+	}
+}
+
+// WithH2C speaks HTTP/2 over cleartext to every plain HTTP host, which has to support it with prior knowledge.
+// Single requests can use h2c without this option through the "h2c" scheme, like "h2c://service/status".
+func WithH2C() Option {
+	return func(o *options) {
+		o.h2c = true
+	}
+}
+
+// setupTransports registers the unix and h2c schemes on the transport and routes socket hosts to their sockets.
+// dial is how all TCP connections are made, it returns the h2c transport so its connections can be closed too.
+func (o *options) setupTransports(dial dialFunc) *http2.Transport {
+	sockets := o.unixSockets
+	if len(sockets) > 0 {
+		o.transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				if socketPath, ok := sockets[strings.ToLower(host)]; ok {
+					return o.dialer.DialContext(ctx, "unix", socketPath)
+				}
+			}
+			return dial(ctx, network, address)
+		}
+
+		if proxy := o.transport.Proxy; proxy != nil {
+			o.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+				if _, ok := sockets[strings.ToLower(req.URL.Hostname())]; ok {
+					return nil, nil
+				}
+				return proxy(req)
+			}
+		}
+	} else {
+		o.transport.DialContext = dial
+	}
+
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		// Despite its name this dials every connection of the transport, which are all cleartext with AllowHTTP
+		DialTLS: func(network string, address string, _ *tls.Config) (net.Conn, error) {
+			return o.transport.DialContext(context.Background(), network, address)
+		},
+	}
+
+	o.transport.RegisterProtocol("unix", &schemeTransport{
+		next: o.transport,
+		check: func(req *http.Request) error {
+			if _, ok := sockets[strings.ToLower(req.URL.Hostname())]; !ok {
+				return fmt.Errorf("no unix socket configured for host %q", req.URL.Hostname())
+			}
+			return nil
+		},
+	})
+	o.transport.RegisterProtocol("h2c", &schemeTransport{next: h2c})
+	if o.h2c {
+		o.transport.RegisterProtocol("http", h2c)
+	}
+	return h2c
+}
+
+// schemeTransport sends the requests of its own scheme as plain HTTP requests through next
+type schemeTransport struct {
+	next  http.RoundTripper
+	check func(req *http.Request) error
+}
+
+func (t *schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.check != nil {
+		if err := t.check(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+	}
+
+	rewritten := req.Clone(req.Context())
+	rewritten.URL.Scheme = "http"
+	return t.next.RoundTrip(rewritten)
+}
+
+// withUnixSocketConfig applies "host=path" entries
+func withUnixSocketConfig(entries []string) Option {
+	return func(o *options) {
+		for _, entry := range entries {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				o.fail(fmt.Errorf("invalid unix socket %q, expected host=path", entry))
+				return
+			}
+			WithUnixSocket(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))(o)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package nethttp
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"gotest.tools/assert"
+)
+
+// describe answers with the protocol, host and URL of the request it got
+var describe = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto + " " + r.Host + " " + r.URL.String()))
+})
+
+func TestNetHttpClient_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "sidecar.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NilError(t, err)
+	server := &http.Server{Handler: describe}
+	go server.Serve(listener)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		options  []Option
+		url      string
+		wantBody string
+		wantErr  string
+	}{
+		{
+			name:     "Successful--UnixScheme",
+			options:  []Option{WithUnixSocket("sidecar", socketPath)},
+			url:      "unix://sidecar/status?verbose=1",
+			wantBody: "HTTP/1.1 sidecar /status?verbose=1",
+		},
+		{
+			name:     "Successful--HostFromConfig",
+			options:  []Option{WithConfig(util.InfrastructureConfig{HttpUnixSockets: []string{"sidecar=" + socketPath}})},
+			url:      "http://sidecar/status",
+			wantBody: "HTTP/1.1 sidecar /status",
+		},
+		{
+			name:     "Successful--NotProxied",
+			options:  []Option{WithUnixSocket("sidecar", socketPath), WithProxy("http://127.0.0.1:1", nil)},
+			url:      "http://sidecar/status",
+			wantBody: "HTTP/1.1 sidecar /status",
+		},
+		{
+			name:     "Successful--NoEgress",
+			options:  []Option{WithUnixSocket("sidecar", socketPath), WithEgressPolicy(EgressPolicy{Allow: []string{"example.com"}})},
+			url:      "unix://sidecar/status",
+			wantBody: "HTTP/1.1 sidecar /status",
+		},
+		{
+			name:    "Failed--UnknownSocket",
+			options: []Option{WithUnixSocket("sidecar", socketPath)},
+			url:     "unix://other/status",
+			wantErr: `no unix socket configured for host "other"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewNetHttpClient(tt.options...)
+			assert.NilError(t, err)
+
+			resp, err := client.Get(tt.url)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
+
+func TestNetHttpClient_H2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(describe, &http2.Server{}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name      string
+		options   []Option
+		url       string
+		wantProto string
+	}{
+		{
+			name:      "Successful--HTTP1ByDefault",
+			url:       server.URL,
+			wantProto: "HTTP/1.1",
+		},
+		{
+			name:      "Successful--H2CScheme",
+			url:       "h2c://" + host,
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "Successful--H2CFromConfig",
+			options:   []Option{WithConfig(util.InfrastructureConfig{HttpH2C: true})},
+			url:       server.URL,
+			wantProto: "HTTP/2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewNetHttpClient(tt.options...)
+			assert.NilError(t, err)
+
+			resp, err := client.Get(tt.url)
+			assert.NilError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantProto+" "+host+" /", string(body))
+		})
+	}
+}
+
+func TestNewNetHttpClient_InvalidUnixSocket(t *testing.T) {
+	_, err := NewNetHttpClient(withUnixSocketConfig([]string{"/var/run/sidecar.sock"}))
+	assert.Error(t, err, `invalid unix socket "/var/run/sidecar.sock", expected host=path`)
+}
@@ -0,0 +1,40 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+)
+
+// ProgressFunc is called after every read with the number of bytes transferred so far.
+// total is -1 if the length isn't known up front.
+type ProgressFunc func(transferred int64, total int64)
+
+type progressReader struct {
+	io.ReadCloser
+	transferred int64
+	total       int64
+	progress    ProgressFunc
+}
+
+// NewProgressReader returns a reader reporting to progress how much of body was read.
+func NewProgressReader(body io.ReadCloser, total int64, progress ProgressFunc) io.ReadCloser {
+	return &progressReader{ReadCloser: body, total: total, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.progress(r.transferred, r.total)
+	}
+	return n, err
+}
+
+// TrackDownload makes reading the body of resp report to progress.
+func TrackDownload(resp *http.Response, progress ProgressFunc) {
+	total := resp.ContentLength
+	if total < 0 {
+		total = -1
+	}
+	resp.Body = NewProgressReader(resp.Body, total, progress)
+}
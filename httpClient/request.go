@@ -0,0 +1,173 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RequestBuilder assembles an *http.Request for HttpClient.Do step by step.
+// Problems with the URL are reported by Build.
+type RequestBuilder struct {
+	ctx     context.Context
+	method  string
+	baseURL string
+	paths   []string
+	query   url.Values
+	header  http.Header
+	body    io.Reader
+	// length is the body length given with StreamBody, nil if it is to be detected from the body
+	length   *int64
+	progress ProgressFunc
+}
+
+// NewRequestBuilder starts a request for method against baseURL.
+func NewRequestBuilder(method string, baseURL string) *RequestBuilder {
+	return &RequestBuilder{
+		ctx:     context.Background(),
+		method:  method,
+		baseURL: baseURL,
+		query:   url.Values{},
+		header:  http.Header{},
+	}
+}
+
+// WithContext sets the context the request is bound to.
+func (b *RequestBuilder) WithContext(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// Path appends path to the path of the base URL, or of the previous call, taking care of the slashes in between.
+func (b *RequestBuilder) Path(path string) *RequestBuilder {
+	b.paths = append(b.paths, path)
+	return b
+}
+
+// Query adds a query parameter after the ones already present on the base URL, which are kept as they are.
+func (b *RequestBuilder) Query(key string, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Header sets a header for this request only.
+func (b *RequestBuilder) Header(key string, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Body sets the reader the request body is read from. The length is only known for *bytes.Buffer,
+// *bytes.Reader and *strings.Reader, other bodies are sent chunked, see StreamBody.
+func (b *RequestBuilder) Body(body io.Reader) *RequestBuilder {
+	b.body = body
+	b.length = nil
+	return b
+}
+
+// StreamBody sets a body of length bytes which is read while the request is sent.
+// A negative length sends it chunked.
+func (b *RequestBuilder) StreamBody(body io.Reader, length int64) *RequestBuilder {
+	b.body = body
+	b.length = &length
+	return b
+}
+
+// Multipart streams form as the body and sets the matching Content-Type. The request gets no GetBody, see
+// MultipartForm.
+func (b *RequestBuilder) Multipart(form *MultipartForm) *RequestBuilder {
+	b.header.Set("Content-Type", form.ContentType())
+	return b.StreamBody(form.Reader(), -1)
+}
+
+// UploadProgress makes sending the body report to progress.
+func (b *RequestBuilder) UploadProgress(progress ProgressFunc) *RequestBuilder {
+	b.progress = progress
+	return b
+}
+
+// Build joins the URL and returns the resulting request.
+func (b *RequestBuilder) Build() (*http.Request, error) {
+	target, err := url.Parse(b.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range b.paths {
+		joinPath(target, path)
+	}
+
+	if len(b.query) > 0 {
+		if target.RawQuery != "" {
+			target.RawQuery += "&"
+		}
+		target.RawQuery += b.query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, b.method, target.String(), b.body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range b.header {
+		req.Header[key] = values
+	}
+
+	if b.length != nil {
+		switch {
+		case *b.length == 0:
+			req.Body, req.GetBody = http.NoBody, nil
+		case *b.length > 0:
+			req.ContentLength = *b.length
+		default:
+			// Zero is how net/http marks an outgoing body of unknown length
+			req.ContentLength = 0
+		}
+	}
+
+	if b.progress != nil && req.Body != nil && req.Body != http.NoBody {
+		b.trackUpload(req)
+	}
+
+	return req, nil
+}
+
+// JoinURL parses baseURL and appends path to it with exactly one slash in between.
+// Escapes in the path of baseURL, like an encoded slash, are kept.
+func JoinURL(baseURL string, path string) (*url.URL, error) {
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	joinPath(target, path)
+	return target, nil
+}
+
+func joinPath(target *url.URL, path string) {
+	if path == "" {
+		return
+	}
+
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	target.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + "/" + strings.TrimPrefix(escaped, "/")
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// trackUpload wraps the body, and any copy of it made for a retry or redirect, with a progress reader
+func (b *RequestBuilder) trackUpload(req *http.Request) {
+	total := req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+
+	req.Body = NewProgressReader(req.Body, total, b.progress)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return NewProgressReader(body, total, b.progress), nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestRequestBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		wantURL string
+		wantErr bool
+	}{
+		{
+			name:    "Base-Only",
+			builder: NewRequestBuilder(http.MethodGet, "https://test.url.com"),
+			wantURL: "https://test.url.com",
+		},
+		{
+			name:    "Join-Slashes",
+			builder: NewRequestBuilder(http.MethodDelete, "https://test.url.com/api/").Path("/items/1"),
+			wantURL: "https://test.url.com/api/items/1",
+		},
+		{
+			name:    "Join-Without-Slashes",
+			builder: NewRequestBuilder(http.MethodPut, "https://test.url.com/api").Path("items"),
+			wantURL: "https://test.url.com/api/items",
+		},
+		{
+			name:    "Join-Several",
+			builder: NewRequestBuilder(http.MethodGet, "https://test.url.com/api").Path("items").Path("/1/"),
+			wantURL: "https://test.url.com/api/items/1/",
+		},
+		{
+			name:    "Join-Escaped",
+			builder: NewRequestBuilder(http.MethodGet, "https://test.url.com/files/a%2Fb").Path("c d"),
+			wantURL: "https://test.url.com/files/a%2Fb/c%20d",
+		},
+		{
+			name:    "Merge-Query",
+			builder: NewRequestBuilder(http.MethodGet, "https://test.url.com/?a=1").Query("b", "2").Query("b", "3"),
+			wantURL: "https://test.url.com/?a=1&b=2&b=3",
+		},
+		{
+			name:    "Merge-Query-Keeps-Existing",
+			builder: NewRequestBuilder(http.MethodGet, "https://test.url.com/?z=1&a=%7e&flag").Query("b", "x y"),
+			wantURL: "https://test.url.com/?z=1&a=%7e&flag&b=x+y",
+		},
+		{
+			name:    "Failed--Invalid-URL",
+			builder: NewRequestBuilder(http.MethodGet, "://no-scheme"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.builder.Build()
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantURL, req.URL.String())
+			}
+		})
+	}
+}
+
+func TestRequestBuilder_HeaderAndBody(t *testing.T) {
+	req, err := NewRequestBuilder(http.MethodPatch, "https://test.url.com").
+		Header("Content-Type", "application/json").
+		Body(strings.NewReader(`{"key":"value"}`)).
+		Build()
+	assert.NilError(t, err)
+
+	body, err := ioutil.ReadAll(req.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, http.MethodPatch, req.Method)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, `{"key":"value"}`, string(body))
+	assert.Equal(t, int64(len(body)), req.ContentLength)
+}
+
+func TestRequestBuilder_Streaming(t *testing.T) {
+	type received struct {
+		contentLength    int64
+		transferEncoding []string
+		body             string
+		fields           map[string]string
+		files            map[string]string
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := received{contentLength: r.ContentLength, transferEncoding: r.TransferEncoding}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			reader, err := r.MultipartReader()
+			assert.NilError(t, err)
+			got.fields, got.files = map[string]string{}, map[string]string{}
+			for {
+				part, err := reader.NextPart()
+				if err != nil {
+					break
+				}
+				content, _ := ioutil.ReadAll(part)
+				if part.FileName() != "" {
+					got.files[part.FormName()] = part.FileName() + ":" + string(content)
+				} else {
+					got.fields[part.FormName()] = string(content)
+				}
+			}
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			got.body = string(body)
+		}
+		requests <- got
+		w.Write([]byte("response body"))
+	}))
+	defer server.Close()
+
+	t.Run("Known-Length", func(t *testing.T) {
+		var uploaded []int64
+		req, err := NewRequestBuilder(http.MethodPut, server.URL).
+			StreamBody(ioutil.NopCloser(strings.NewReader("streamed")), 8).
+			UploadProgress(func(transferred int64, total int64) {
+				assert.Equal(t, int64(8), total)
+				uploaded = append(uploaded, transferred)
+			}).
+			Build()
+		assert.NilError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+
+		var downloaded int64
+		TrackDownload(resp, func(transferred int64, total int64) {
+			downloaded = transferred
+		})
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		got := <-requests
+		assert.Equal(t, int64(8), got.contentLength)
+		assert.Equal(t, "streamed", got.body)
+		assert.Equal(t, int64(8), uploaded[len(uploaded)-1])
+		assert.Equal(t, int64(len("response body")), downloaded)
+	})
+
+	t.Run("Chunked-Multipart", func(t *testing.T) {
+		file := newCloseRecorder("file content")
+		form := NewMultipartForm().
+			Field("description", "test upload").
+			File("upload", "data.txt", file)
+		req, err := NewRequestBuilder(http.MethodPost, server.URL).Multipart(form).Build()
+		assert.NilError(t, err)
+		assert.Assert(t, req.GetBody == nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		resp.Body.Close()
+
+		got := <-requests
+		assert.DeepEqual(t, []string{"chunked"}, got.transferEncoding)
+		assert.DeepEqual(t, map[string]string{"description": "test upload"}, got.fields)
+		assert.DeepEqual(t, map[string]string{"upload": "data.txt:file content"}, got.files)
+		file.waitClosed(t)
+	})
+
+	t.Run("Multipart-Closed-Early", func(t *testing.T) {
+		first := newCloseRecorder("first")
+		second := newCloseRecorder("second")
+		body := NewMultipartForm().File("first", "first.txt", first).File("second", "second.txt", second).Reader()
+		body.Close()
+
+		// The writer notices on its first write and gives up, closing what it didn't send as well
+		first.waitClosed(t)
+		second.waitClosed(t)
+	})
+}
+
+// closeRecorder is a file-like content whose closed channel is closed along with it
+type closeRecorder struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func newCloseRecorder(content string) *closeRecorder {
+	return &closeRecorder{Reader: strings.NewReader(content), closed: make(chan struct{})}
+}
+
+func (r *closeRecorder) Close() error {
+	close(r.closed)
+	return nil
+}
+
+// waitClosed fails the test unless r is closed within a second
+func (r *closeRecorder) waitClosed(t *testing.T) {
+	select {
+	case <-r.closed:
+	case <-time.After(time.Second):
+		t.Error("content was not closed")
+	}
+}
@@ -0,0 +1,157 @@
+// Package responselimit keeps responses that are too large to be read into memory away from the callers of an HttpClient.
+package responselimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+)
+
+// maxDrainBytes is how much of an unread body is read on Close so the connection can be reused,
+// anything longer is cheaper to throw away together with the connection
+const maxDrainBytes = 64 << 10
+
+// TooLargeError is returned when a response body is larger than the limit, either right away because of its
+// Content-Length or while reading the body.
+type TooLargeError struct {
+	Limit int64
+	// ContentLength is the announced size, -1 if the size wasn't known in advance
+	ContentLength int64
+}
+
+func (e *TooLargeError) Error() string {
+	if e.ContentLength >= 0 {
+		return fmt.Sprintf("response body of %d bytes exceeds the limit of %d bytes", e.ContentLength, e.Limit)
+	}
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+type limitKey struct{}
+
+// WithMaxResponseBytes overrides the limit of the client for the requests sent with the returned context.
+func WithMaxResponseBytes(ctx context.Context, limit int64) context.Context {
+	return context.WithValue(ctx, limitKey{}, limit)
+}
+
+type responseLimitClient struct {
+	client    httpclient.HttpClient
+	errorUtil errorHelper.Helper
+	limit     int64
+}
+
+// NewResponseLimitClient returns an HttpClient that fails responses whose body is larger than HttpMaxResponseBytes,
+// 10 MiB if not set, with a TooLargeError. Responses announcing a larger Content-Length are closed without being
+// read, others fail once the body read exceeds the limit.
+func NewResponseLimitClient(client httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig) httpclient.HttpClient {
+	limit := config.HttpMaxResponseBytes
+	if limit <= 0 {
+		limit = 10 << 20
+	}
+
+	return &responseLimitClient{
+		client:    client,
+		errorUtil: errorUtil,
+		limit:     limit,
+	}
+}
+
+func (c *responseLimitClient) Get(url string) (*http.Response, error) {
+	return c.call(context.Background(), func() (*http.Response, error) {
+		return c.client.Get(url)
+	})
+}
+
+func (c *responseLimitClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(context.Background(), func() (*http.Response, error) {
+		return c.client.Post(url, contentType, body)
+	})
+}
+
+func (c *responseLimitClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.call(ctx, func() (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *responseLimitClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.call(ctx, func() (*http.Response, error) {
+		return c.client.PostWithContext(ctx, url, contentType, body)
+	})
+}
+
+func (c *responseLimitClient) Do(req *http.Request) (*http.Response, error) {
+	return c.call(req.Context(), func() (*http.Response, error) {
+		return c.client.Do(req)
+	})
+}
+
+func (c *responseLimitClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *responseLimitClient) call(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	resp, err := send()
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+
+	limit := c.limit
+	if override, ok := ctx.Value(limitKey{}).(int64); ok && override > 0 {
+		limit = override
+	}
+
+	if resp.ContentLength > limit {
+		// Closing without reading lets the transport drop the connection instead of downloading the body
+		resp.Body.Close()
+		return nil, c.errorUtil.WithStack(&TooLargeError{Limit: limit, ContentLength: resp.ContentLength})
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, limit: limit, errorUtil: c.errorUtil}
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	errorUtil errorHelper.Helper
+
+	read int64
+	eof  bool
+	err  error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	// Reading one byte past the limit tells a body of exactly limit bytes apart from a longer one
+	if room := b.limit - b.read + 1; int64(len(p)) > room {
+		p = p[:room]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		b.err = b.errorUtil.WithStack(&TooLargeError{Limit: b.limit, ContentLength: -1})
+		return n - int(b.read-b.limit), b.err
+	}
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// Close drains a short rest of the body so the connection can be reused, a body past the limit is not drained
+func (b *limitedBody) Close() error {
+	if b.err == nil && !b.eof {
+		io.CopyN(ioutil.Discard, b.ReadCloser, maxDrainBytes)
+	}
+	return b.ReadCloser.Close()
+}
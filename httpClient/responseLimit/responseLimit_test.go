@@ -0,0 +1,153 @@
+package responselimit
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	"github.com/Kasparund/Go-Action-Test-Overload/util"
+	"gotest.tools/assert"
+)
+
+// trackedBody remembers how much of it was read and whether it was closed
+type trackedBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestResponseLimitClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		size          int
+		contentLength int64
+		wantBody      int
+		wantErr       *TooLargeError
+		wantUnread    int
+	}{
+		{
+			name:          "Successful--WithinLimit",
+			ctx:           context.Background(),
+			size:          100,
+			contentLength: 100,
+			wantBody:      100,
+		},
+		{
+			name:          "Successful--ExactlyAtLimitWithoutLength",
+			ctx:           context.Background(),
+			size:          100,
+			contentLength: -1,
+			wantBody:      100,
+		},
+		{
+			name:          "Failed--ContentLengthTooLarge",
+			ctx:           context.Background(),
+			size:          101,
+			contentLength: 101,
+			wantErr:       &TooLargeError{Limit: 100, ContentLength: 101},
+			wantUnread:    101,
+		},
+		{
+			name:          "Failed--BodyTooLarge",
+			ctx:           context.Background(),
+			size:          1 << 20,
+			contentLength: -1,
+			wantBody:      100,
+			wantErr:       &TooLargeError{Limit: 100, ContentLength: -1},
+			wantUnread:    1<<20 - 101,
+		},
+		{
+			name:          "Successful--RequestRaisesLimit",
+			ctx:           WithMaxResponseBytes(context.Background(), 200),
+			size:          150,
+			contentLength: 150,
+			wantBody:      150,
+		},
+		{
+			name:          "Failed--RequestLowersLimit",
+			ctx:           WithMaxResponseBytes(context.Background(), 10),
+			size:          50,
+			contentLength: 50,
+			wantErr:       &TooLargeError{Limit: 10, ContentLength: 50},
+			wantUnread:    50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(strings.Repeat("a", tt.size))}
+			inner := fake.NewFakeClient()
+			inner.On(http.MethodGet, "https://test.url.com").Handle(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: body, ContentLength: tt.contentLength}, nil
+			})
+			client := NewResponseLimitClient(inner, errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpMaxResponseBytes: 100})
+
+			read := 0
+			resp, err := client.GetWithContext(tt.ctx, "https://test.url.com")
+			if err == nil {
+				var data []byte
+				data, err = ioutil.ReadAll(resp.Body)
+				read = len(data)
+				resp.Body.Close()
+			}
+
+			assert.Equal(t, tt.wantBody, read)
+			assert.Assert(t, body.closed)
+			assert.Equal(t, tt.wantUnread, body.Len())
+			if tt.wantErr == nil {
+				assert.NilError(t, err)
+				return
+			}
+			var tooLarge *TooLargeError
+			assert.Assert(t, errors.As(err, &tooLarge))
+			assert.DeepEqual(t, tt.wantErr, tooLarge)
+		})
+	}
+}
+
+func TestResponseLimitClient_DrainOnClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		wantUnread int
+	}{
+		{
+			name:       "Successful--ShortRestDrained",
+			size:       1 << 10,
+			wantUnread: 0,
+		},
+		{
+			name:       "Successful--LongRestLeftForTheConnection",
+			size:       1 << 20,
+			wantUnread: 1<<20 - maxDrainBytes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackedBody{Reader: strings.NewReader(strings.Repeat("a", tt.size))}
+			inner := fake.NewFakeClient()
+			inner.On(http.MethodPost, "https://test.url.com").Handle(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusCreated, Body: body, ContentLength: -1}, nil
+			})
+			client := NewResponseLimitClient(inner, errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpMaxResponseBytes: 2 << 20})
+
+			resp, err := client.Post("https://test.url.com", "application/json", nil)
+			assert.NilError(t, err)
+			resp.Body.Close()
+
+			assert.Assert(t, body.closed)
+			assert.Equal(t, tt.wantUnread, body.Len())
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package retry wraps an HttpClient so failed requests are sent again with exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// maxDrainBytes is how much of a retried response is read to reuse its connection, larger bodies close it instead
+const maxDrainBytes = 64 << 10
+
+type Config struct {
+	// MaxAttempts is the total number of tries, including the first one. Defaults to 3.
+	MaxAttempts int
+	// BaseDelay is the backoff cap for the first retry; it doubles on every further retry. Defaults to 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff as well as any Retry-After the server asks for. Defaults to 10s.
+	MaxDelay time.Duration
+}
+
+type retryClient struct {
+	client httpclient.HttpClient
+	config Config
+	// random returns a number in [0, 1) and is used for the backoff jitter
+	random func() float64
+}
+
+type retryableKey struct{}
+
+// MarkRetryable returns a context which allows requests that are not idempotent, like POST, to be retried.
+func MarkRetryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableKey{}, true)
+}
+
+func isMarkedRetryable(ctx context.Context) bool {
+	marked, _ := ctx.Value(retryableKey{}).(bool)
+	return marked
+}
+
+// NewRetryClient returns an HttpClient that retries the requests of client on network errors and
+// on 429 and 5xx responses. Only idempotent requests and requests marked with MarkRetryable are retried.
+func NewRetryClient(client httpclient.HttpClient, config Config) httpclient.HttpClient {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 100 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+
+	return &retryClient{
+		client: client,
+		config: config,
+		random: rand.Float64,
+	}
+}
+
+func (c *retryClient) Get(url string) (*http.Response, error) {
+	return c.retry(context.Background(), true, func() (*http.Response, error) {
+		return c.client.Get(url)
+	})
+}
+
+// Post is never retried as there is no context to mark it retryable with, use PostWithContext instead.
+func (c *retryClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.client.Post(url, contentType, body)
+}
+
+func (c *retryClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.retry(ctx, true, func() (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *retryClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	// Every attempt gets its own copy, so the wrapped client can't affect later attempts by modifying it
+	replay := append([]byte(nil), body...)
+	return c.retry(ctx, isMarkedRetryable(ctx), func() (*http.Response, error) {
+		return c.client.PostWithContext(ctx, url, contentType, append([]byte(nil), replay...))
+	})
+}
+
+func (c *retryClient) Do(req *http.Request) (*http.Response, error) {
+	retryable := httpclient.IsIdempotent(req) || isMarkedRetryable(req.Context())
+
+	// A body that can't be recreated can only be sent once
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retryable = false
+	}
+
+	attempt := 0
+	return c.retry(req.Context(), retryable, func() (*http.Response, error) {
+		attempt++
+		if attempt == 1 || req.GetBody == nil {
+			return c.client.Do(req)
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		replay := req.Clone(req.Context())
+		replay.Body = body
+		return c.client.Do(replay)
+	})
+}
+
+func (c *retryClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *retryClient) retry(ctx context.Context, retryable bool, send func() (*http.Response, error)) (*http.Response, error) {
+	maxAttempts := 1
+	if retryable {
+		maxAttempts = c.config.MaxAttempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := send()
+		if attempt+1 >= maxAttempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := c.delay(attempt, resp)
+		if !ok {
+			// The server asked us to wait longer than we are willing to
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.CopyN(ioutil.Discard, resp.Body, maxDrainBytes)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt, using full jitter unless the server sent a Retry-After
+func (c *retryClient) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return retryAfter, retryAfter <= c.config.MaxDelay
+		}
+	}
+
+	backoff := float64(c.config.BaseDelay) * math.Pow(2, float64(attempt))
+	if backoff > float64(c.config.MaxDelay) {
+		backoff = float64(c.config.MaxDelay)
+	}
+	return time.Duration(c.random() * backoff), true
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// Errors caused by our own cancellation won't go away by trying again
+		return ctx.Err() == nil && isTransient(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isTransient reports whether err came from the network and may be gone on the next attempt. Errors raised by the
+// client itself, like a denied egress, a pin mismatch, an open circuit or a shut down client, are final.
+func isTransient(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	// A dial can also fail in Dialer.Control, which is where the egress policy denies it
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		var syscallErr *os.SyscallError
+		return opErr.Timeout() || errors.As(opErr.Err, &syscallErr)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter supports both forms of the header, delay in seconds and an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := time.Until(date)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
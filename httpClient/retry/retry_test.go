@@ -0,0 +1,219 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	circuitbreaker "github.com/Kasparund/Go-Action-Test-Overload/httpClient/circuitBreaker"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/mocks"
+	nethttp "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	responselimit "github.com/Kasparund/Go-Action-Test-Overload/httpClient/responseLimit"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+// reset is what net/http returns when the server drops the connection
+var reset = &url.Error{Op: "Get", URL: "https://test.url.com", Err: &net.OpError{
+	Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET),
+}}
+
+func response(statusCode int, header http.Header) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+}
+
+func TestRetryClient_Get(t *testing.T) {
+	tests := []struct {
+		name       string
+		responses  []*http.Response
+		errs       []error
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			name:       "Successful--First-Attempt",
+			responses:  []*http.Response{response(200, nil)},
+			errs:       []error{nil},
+			wantStatus: 200,
+		},
+		{
+			name:       "Successful--After-Transport-Error",
+			responses:  []*http.Response{nil, response(200, nil)},
+			errs:       []error{reset, nil},
+			wantStatus: 200,
+		},
+		{
+			name:       "Successful--After-Retry-After",
+			responses:  []*http.Response{response(503, http.Header{"Retry-After": {"0"}}), response(200, nil)},
+			errs:       []error{nil, nil},
+			wantStatus: 200,
+		},
+		{
+			name:       "Failed--Attempts-Exhausted",
+			responses:  []*http.Response{response(500, nil), response(502, nil), response(504, nil)},
+			errs:       []error{nil, nil, nil},
+			wantStatus: 504,
+		},
+		{
+			name:      "Failed--Client-Side-Error-Not-Retried",
+			responses: []*http.Response{nil},
+			errs:      []error{errorUtil.NewErrorUtil().WithStack(httpclient.ErrShutdown)},
+			wantErr:   true,
+		},
+		{
+			name:       "Failed--Client-Error-Not-Retried",
+			responses:  []*http.Response{response(404, nil)},
+			errs:       []error{nil},
+			wantStatus: 404,
+		},
+		{
+			name:       "Failed--Retry-After-Too-Long",
+			responses:  []*http.Response{response(429, http.Header{"Retry-After": {"3600"}})},
+			errs:       []error{nil},
+			wantStatus: 429,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			inner := mocks.NewMockHttpClient(ctrl)
+			calls := make([]*gomock.Call, len(tt.responses))
+			for i := range tt.responses {
+				calls[i] = inner.EXPECT().Get("https://test.url.com").Return(tt.responses[i], tt.errs[i])
+			}
+			gomock.InOrder(calls...)
+
+			client := NewRetryClient(inner, Config{BaseDelay: time.Millisecond})
+			resp, err := client.Get("https://test.url.com")
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantStatus != 0 {
+				assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRetryClient_PostWithContext(t *testing.T) {
+	body := []byte(`{"key":"value"}`)
+
+	t.Run("Not-Marked", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		inner := mocks.NewMockHttpClient(ctrl)
+		inner.EXPECT().PostWithContext(gomock.Any(), "https://test.url.com", "application/json", body).Return(response(503, nil), nil).Times(1)
+
+		client := NewRetryClient(inner, Config{BaseDelay: time.Millisecond})
+		resp, err := client.PostWithContext(context.Background(), "https://test.url.com", "application/json", body)
+
+		assert.NilError(t, err)
+		assert.Equal(t, 503, resp.StatusCode)
+	})
+
+	t.Run("Marked", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		inner := mocks.NewMockHttpClient(ctrl)
+		gomock.InOrder(
+			inner.EXPECT().PostWithContext(gomock.Any(), "https://test.url.com", "application/json", body).
+				DoAndReturn(func(_ context.Context, _ string, _ string, sent []byte) (*http.Response, error) {
+					// Scribble over the body to make sure the next attempt doesn't see it
+					sent[0] = 'X'
+					return response(503, nil), nil
+				}),
+			inner.EXPECT().PostWithContext(gomock.Any(), "https://test.url.com", "application/json", body).Return(response(201, nil), nil),
+		)
+
+		client := NewRetryClient(inner, Config{BaseDelay: time.Millisecond})
+		resp, err := client.PostWithContext(MarkRetryable(context.Background()), "https://test.url.com", "application/json", body)
+
+		assert.NilError(t, err)
+		assert.Equal(t, 201, resp.StatusCode)
+	})
+}
+
+func TestRetryClient_Do(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	inner := mocks.NewMockHttpClient(ctrl)
+
+	var bodies []string
+	inner.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			return nil, reset
+		}
+		return response(200, nil), nil
+	}).Times(3)
+
+	req, _ := http.NewRequest(http.MethodPut, "https://test.url.com", strings.NewReader("payload"))
+	client := NewRetryClient(inner, Config{BaseDelay: time.Millisecond})
+	resp, err := client.Do(req)
+
+	assert.NilError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.DeepEqual(t, []string{"payload", "payload", "payload"}, bodies)
+}
+
+func TestIsTransient(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://test.url.com", Err: err}
+	}
+	dialError := func(err error) error {
+		return urlError(&net.OpError{Op: "dial", Net: "tcp", Err: err})
+	}
+	timeout := &net.DNSError{Err: "i/o timeout", Name: "test.url.com", IsTimeout: true}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Successful--Connection-Reset", err: reset, want: true},
+		{name: "Successful--Connection-Refused", err: dialError(os.NewSyscallError("connect", syscall.ECONNREFUSED)), want: true},
+		{name: "Successful--Unexpected-EOF", err: urlError(io.ErrUnexpectedEOF), want: true},
+		{name: "Successful--DNS-Timeout", err: dialError(timeout), want: true},
+		{name: "Successful--Client-Timeout", err: urlError(context.DeadlineExceeded), want: true},
+		{name: "Failed--DNS-Not-Found", err: dialError(&net.DNSError{Err: "no such host", Name: "test.url.com", IsNotFound: true})},
+		{name: "Failed--Egress-Denied", err: dialError(&nethttp.EgressError{Host: "localhost", IP: "127.0.0.1", Reason: "private address"})},
+		{name: "Failed--Pin-Mismatch", err: urlError(&nethttp.PinError{Host: "test.url.com"})},
+		{name: "Failed--Circuit-Open", err: &circuitbreaker.OpenError{RetryAt: time.Now()}},
+		{name: "Failed--Too-Large", err: &responselimit.TooLargeError{Limit: 1, ContentLength: 2}},
+		{name: "Failed--Shut-Down", err: errorUtil.NewErrorUtil().WithStack(httpclient.ErrShutdown)},
+		{name: "Failed--Invalid-URL", err: fmt.Errorf("building request: %w", &url.Error{Op: "parse", URL: ":", Err: errors.New("missing protocol scheme")})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("2")
+	assert.Assert(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+
+	delay, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.Assert(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("soon")
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,173 @@
+// Package singleflight collapses identical concurrent GET requests of an HttpClient into one.
+package singleflight
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+)
+
+// credentialHeaders are always part of the key, so callers with different credentials never share a response
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key"}
+
+// call is one upstream request shared by everyone asking for the same key while it runs
+type call struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+
+	// waiters is guarded by the mutex of the client, the upstream request is cancelled once nobody waits anymore
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type singleFlightClient struct {
+	client  httpclient.HttpClient
+	headers []string
+
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+// NewSingleFlightClient returns an HttpClient that sends a GET only once while the same GET is already in flight,
+// handing every caller its own copy of the response. Requests are the same if their URL and the values of
+// keyHeaders match, e.g. "Accept" when the response depends on it. Credential headers like Authorization, Cookie
+// and X-API-Key are always compared, so no caller gets a response meant for someone else.
+//
+// The shared request runs without the context of any single caller, so a caller giving up doesn't fail the
+// others; it is cancelled once all callers gave up. Values carried in the context don't reach the wrapped client.
+func NewSingleFlightClient(client httpclient.HttpClient, keyHeaders ...string) httpclient.HttpClient {
+	headers := make([]string, 0, len(credentialHeaders)+len(keyHeaders))
+	seen := map[string]bool{}
+	for _, name := range append(credentialHeaders, keyHeaders...) {
+		name = http.CanonicalHeaderKey(name)
+		if !seen[name] {
+			seen[name] = true
+			headers = append(headers, name)
+		}
+	}
+
+	return &singleFlightClient{
+		client:  client,
+		headers: headers,
+		calls:   map[string]*call{},
+	}
+}
+
+func (c *singleFlightClient) Get(url string) (*http.Response, error) {
+	return c.flight(context.Background(), c.key(url, nil), func(ctx context.Context) (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *singleFlightClient) Post(url string, contentType string, body []byte) (*http.Response, error) {
+	return c.client.Post(url, contentType, body)
+}
+
+func (c *singleFlightClient) GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.flight(ctx, c.key(url, nil), func(ctx context.Context) (*http.Response, error) {
+		return c.client.GetWithContext(ctx, url)
+	})
+}
+
+func (c *singleFlightClient) PostWithContext(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.client.PostWithContext(ctx, url, contentType, body)
+}
+
+func (c *singleFlightClient) Do(req *http.Request) (*http.Response, error) {
+	isGet := req.Method == http.MethodGet || req.Method == ""
+	if !isGet || (req.Body != nil && req.Body != http.NoBody) {
+		return c.client.Do(req)
+	}
+
+	return c.flight(req.Context(), c.key(req.URL.String(), req.Header), func(ctx context.Context) (*http.Response, error) {
+		return c.client.Do(req.Clone(ctx))
+	})
+}
+
+func (c *singleFlightClient) Shutdown(ctx context.Context) error {
+	return c.client.Shutdown(ctx)
+}
+
+func (c *singleFlightClient) flight(ctx context.Context, key string, send func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	c.mutex.Lock()
+	shared, ok := c.calls[key]
+	if !ok {
+		upstreamCtx, cancel := context.WithCancel(context.Background())
+		shared = &call{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = shared
+		go c.run(upstreamCtx, key, shared, send)
+	}
+	shared.waiters++
+	c.mutex.Unlock()
+
+	select {
+	case <-shared.done:
+		return shared.response()
+	case <-ctx.Done():
+		c.mutex.Lock()
+		shared.waiters--
+		if shared.waiters == 0 {
+			shared.cancel()
+			c.forget(key, shared)
+		}
+		c.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *singleFlightClient) run(ctx context.Context, key string, shared *call, send func(ctx context.Context) (*http.Response, error)) {
+	resp, err := send(ctx)
+	if err == nil && resp != nil && resp.Body != nil {
+		shared.body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	shared.resp, shared.err = resp, err
+
+	// Requests from now on get a fresh response
+	c.mutex.Lock()
+	c.forget(key, shared)
+	c.mutex.Unlock()
+
+	shared.cancel()
+	close(shared.done)
+}
+
+// forget must be called with the mutex held, a newer call for key is left alone
+func (c *singleFlightClient) forget(key string, shared *call) {
+	if c.calls[key] == shared {
+		delete(c.calls, key)
+	}
+}
+
+func (c *singleFlightClient) key(url string, header http.Header) string {
+	var key strings.Builder
+	key.WriteString(url)
+	for _, name := range c.headers {
+		key.WriteString("\n")
+		key.WriteString(name)
+		key.WriteString(": ")
+		key.WriteString(strings.Join(header.Values(name), ", "))
+	}
+	return key.String()
+}
+
+// response returns a copy of the shared response, with a body of its own
+func (s *call) response() (*http.Response, error) {
+	if s.err != nil || s.resp == nil {
+		return s.resp, s.err
+	}
+
+	resp := *s.resp
+	resp.Header = s.resp.Header.Clone()
+	resp.Trailer = s.resp.Trailer.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(s.body))
+	resp.ContentLength = int64(len(s.body))
+	return &resp, nil
+}
@@ -0,0 +1,197 @@
+package singleflight
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
+	"gotest.tools/assert"
+)
+
+// blockingBackend answers every request once release is closed
+func blockingBackend(release <-chan struct{}) *fake.Client {
+	inner := fake.NewFakeClient()
+	inner.On("*", "https://test.url.com/*").Handle(func(req *http.Request) (*http.Response, error) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"key":"value"}`)),
+		}, nil
+	})
+	return inner
+}
+
+func TestSingleFlightClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		requests  func() []*http.Request
+		wantCalls int
+	}{
+		{
+			name: "Successful--IdenticalCollapsed",
+			requests: func() []*http.Request {
+				var requests []*http.Request
+				for i := 0; i < 5; i++ {
+					req, _ := http.NewRequest(http.MethodGet, "https://test.url.com/items", nil)
+					req.Header.Set("Accept", "application/json")
+					requests = append(requests, req)
+				}
+				return requests
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "Successful--KeyHeadersDiffer",
+			requests: func() []*http.Request {
+				first, _ := http.NewRequest(http.MethodGet, "https://test.url.com/items", nil)
+				first.Header.Set("Accept", "application/json")
+				second, _ := http.NewRequest(http.MethodGet, "https://test.url.com/items", nil)
+				second.Header.Set("Accept", "application/xml")
+				// Headers not in the key don't matter
+				third, _ := http.NewRequest(http.MethodGet, "https://test.url.com/items", nil)
+				third.Header.Set("Accept", "application/xml")
+				third.Header.Set("X-Request-Id", "3")
+				return []*http.Request{first, second, third}
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "Successful--CredentialsDiffer",
+			requests: func() []*http.Request {
+				var requests []*http.Request
+				for _, credential := range [][2]string{
+					{"Authorization", "Bearer alice"}, {"Authorization", "Bearer bob"},
+					{"Cookie", "session=alice"}, {"X-API-Key", "alice"}, {"X-API-Key", "alice"},
+				} {
+					req, _ := http.NewRequest(http.MethodGet, "https://test.url.com/items", nil)
+					req.Header.Set("Accept", "application/json")
+					req.Header.Set(credential[0], credential[1])
+					requests = append(requests, req)
+				}
+				return requests
+			},
+			wantCalls: 4,
+		},
+		{
+			name: "Successful--OtherMethodsNotCollapsed",
+			requests: func() []*http.Request {
+				first, _ := http.NewRequest(http.MethodDelete, "https://test.url.com/items", nil)
+				second, _ := http.NewRequest(http.MethodDelete, "https://test.url.com/items", nil)
+				return []*http.Request{first, second}
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			inner := blockingBackend(release)
+			client := NewSingleFlightClient(inner, "accept")
+
+			requests := tt.requests()
+			bodies := make([]string, len(requests))
+			var wg sync.WaitGroup
+			for i, req := range requests {
+				wg.Add(1)
+				go func(i int, req *http.Request) {
+					defer wg.Done()
+					resp, err := client.Do(req)
+					assert.Check(t, err)
+					body, _ := ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+					bodies[i] = string(body)
+				}(i, req)
+			}
+
+			// Every collapsible request joined a call and the others reached the backend
+			collapsible := 0
+			for _, req := range requests {
+				if req.Method == http.MethodGet {
+					collapsible++
+				}
+			}
+			waitFor(t, func() bool {
+				return waiters(client) == collapsible && len(inner.Calls()) == tt.wantCalls
+			})
+			close(release)
+			wg.Wait()
+
+			assert.Equal(t, tt.wantCalls, len(inner.Calls()))
+			for _, body := range bodies {
+				assert.Equal(t, `{"key":"value"}`, body)
+			}
+		})
+	}
+}
+
+func TestSingleFlightClient_Cancellation(t *testing.T) {
+	release := make(chan struct{})
+	inner := blockingBackend(release)
+	client := NewSingleFlightClient(inner)
+
+	// One caller giving up doesn't affect the other one
+	impatient, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.GetWithContext(impatient, "https://test.url.com/items")
+		errs <- err
+	}()
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.Get("https://test.url.com/items")
+		assert.Check(t, err)
+		responses <- resp
+	}()
+
+	waitFor(t, func() bool { return waiters(client) == 2 })
+	cancel()
+	assert.Equal(t, context.Canceled, <-errs)
+
+	close(release)
+	resp := <-responses
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, len(inner.Calls()))
+
+	// Once everyone gave up the shared request is cancelled and the next one starts afresh
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	blocked := blockingBackend(make(chan struct{}))
+	client = NewSingleFlightClient(blocked)
+	_, err := client.GetWithContext(ctx, "https://test.url.com/items")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, len(client.(*singleFlightClient).calls))
+}
+
+// waitFor fails the test unless condition is met within a second
+func waitFor(t *testing.T, condition func() bool) {
+	for deadline := time.Now().Add(time.Second); !condition(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+	}
+}
+
+// waiters counts the callers waiting for the running calls of client
+func waiters(client httpclient.HttpClient) int {
+	c := client.(*singleFlightClient)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	count := 0
+	for _, shared := range c.calls {
+		count += shared.waiters
+	}
+	return count
+}
@@ -1,57 +1,128 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
 	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
 	httpclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/auth"
+	loadbalancer "github.com/Kasparund/Go-Action-Test-Overload/httpClient/loadBalancer"
 	netclient "github.com/Kasparund/Go-Action-Test-Overload/httpClient/netHTTP"
+	responselimit "github.com/Kasparund/Go-Action-Test-Overload/httpClient/responseLimit"
 	jsonHandler "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler"
 	json "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler/json"
 	"github.com/Kasparund/Go-Action-Test-Overload/util"
 )
 
 func main() {
-	httpClient := netclient.NewNetHttpClient()
+	config, _ := util.LoadInfrastructureConfig()
+	httpClient, err := netclient.NewNetHttpClient(netclient.WithConfig(config))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	errorHandler := errorUtil.NewErrorUtil()
 	jsonHandler := json.NewJSONHandler()
-	config, _ := util.LoadInfrastructureConfig()
-	service := NewService(httpClient, errorHandler, config, jsonHandler)
+	httpClient = responselimit.NewResponseLimitClient(httpClient, errorHandler, config)
 
-	response, err := service.StartProcess()
+	authProvider, err := auth.NewProviderFromConfig(config, httpClient, errorHandler, jsonHandler)
 	if err != nil {
 		fmt.Println(err)
+		return
+	}
+	// The token endpoint isn't one of the replicas, so only the service requests are load balanced
+	if len(config.LoadBalancerBackends) > 0 {
+		httpClient, err = loadbalancer.NewLoadBalancerClient(httpClient, errorHandler, config)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if authProvider != nil {
+		httpClient = auth.NewAuthClient(httpClient, authProvider)
+	}
+	service := NewService(httpClient, errorHandler, config, jsonHandler)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		response, err := service.StartProcess()
+		if err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println(response)
+	}()
+
+	select {
+	case <-done:
+	case <-signals:
+	}
+
+	shutdownTimeout := config.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Lets a request still in flight after a signal finish, or cancels it once the timeout passed
+	if err := httpClient.Shutdown(ctx); err != nil {
+		fmt.Println(err)
 	}
-	fmt.Println(response)
+	<-done
 }
 
 type Service interface {
 	StartProcess() (response string, err error)
+	// StartProcessWithContext runs the same process as StartProcess, with ctx bounding the whole marshal, post and read sequence.
+	StartProcessWithContext(ctx context.Context) (response string, err error)
 }
 
 type service struct {
 	httpClient  httpclient.HttpClient
 	errorUtil   errorHelper.Helper
 	jsonHandler jsonHandler.JSONHandler
+	url         string
 }
 
 func NewService(httpClient httpclient.HttpClient, errorUtil errorHelper.Helper, config util.InfrastructureConfig, jsonHandler jsonHandler.JSONHandler) Service {
 	fmt.Println(config.ConfigName)
-	return &service{httpClient, errorUtil, jsonHandler}
+	url := config.ServiceURL
+	if url == "" {
+		url = "https://test.url.com"
+	}
+	return &service{httpClient, errorUtil, jsonHandler, url}
 }
 
 func (of *service) StartProcess() (response string, err error) {
+	return of.StartProcessWithContext(context.Background())
+}
+
+func (of *service) StartProcessWithContext(ctx context.Context) (response string, err error) {
 	request := Request{Key: "value"}
 	requestBody, err := of.jsonHandler.Marshal(request)
 	if err != nil {
 		return
 	}
 
-	resp, err := of.httpClient.Post("https://test.url.com", "application/json", requestBody)
+	// Don't start the request if the deadline already passed while marshalling
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	resp, err := of.httpClient.PostWithContext(ctx, of.url, "application/json", requestBody)
 	if err != nil {
 		return
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -11,7 +12,10 @@ import (
 
 	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper"
 	"github.com/Kasparund/Go-Action-Test-Overload/errorHelper/errorUtil"
+	"github.com/Kasparund/Go-Action-Test-Overload/httpClient/fake"
 	mockInterface "github.com/Kasparund/Go-Action-Test-Overload/httpClient/mocks"
+	responselimit "github.com/Kasparund/Go-Action-Test-Overload/httpClient/responseLimit"
+	jsonLib "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler/json"
 	jsonHandlerMock "github.com/Kasparund/Go-Action-Test-Overload/jsonHandler/mocks"
 	"github.com/Kasparund/Go-Action-Test-Overload/util"
 
@@ -127,7 +131,7 @@ func Test_service_StartProcess01(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -228,7 +232,7 @@ func Test_service_StartProcess02(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -329,7 +333,7 @@ func Test_service_StartProcess03(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -430,7 +434,7 @@ func Test_service_StartProcess04(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -531,7 +535,7 @@ func Test_service_StartProcess05(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -632,7 +636,7 @@ func Test_service_StartProcess06(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -733,7 +737,7 @@ func Test_service_StartProcess07(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -834,7 +838,7 @@ func Test_service_StartProcess08(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -935,7 +939,7 @@ func Test_service_StartProcess09(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1036,7 +1040,7 @@ func Test_service_StartProcess10(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1137,7 +1141,7 @@ func Test_service_StartProcess11(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1238,7 +1242,7 @@ func Test_service_StartProcess12(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1339,7 +1343,7 @@ func Test_service_StartProcess13(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1440,7 +1444,7 @@ func Test_service_StartProcess14(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1541,7 +1545,7 @@ func Test_service_StartProcess15(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1642,7 +1646,7 @@ func Test_service_StartProcess16(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1743,7 +1747,7 @@ func Test_service_StartProcess17(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1844,7 +1848,7 @@ func Test_service_StartProcess18(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -1945,7 +1949,7 @@ func Test_service_StartProcess19(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2046,7 +2050,7 @@ func Test_service_StartProcess21(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2147,7 +2151,7 @@ func Test_service_StartProcess22(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2248,7 +2252,7 @@ func Test_service_StartProcess23(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2349,7 +2353,7 @@ func Test_service_StartProcess24(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2450,7 +2454,7 @@ func Test_service_StartProcess25(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2551,7 +2555,7 @@ func Test_service_StartProcess26(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2652,7 +2656,7 @@ func Test_service_StartProcess27(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2753,7 +2757,7 @@ func Test_service_StartProcess28(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2854,7 +2858,7 @@ func Test_service_StartProcess29(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -2955,7 +2959,7 @@ func Test_service_StartProcess30(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3056,7 +3060,7 @@ func Test_service_StartProcess31(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3157,7 +3161,7 @@ func Test_service_StartProcess32(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3258,7 +3262,7 @@ func Test_service_StartProcess33(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3359,7 +3363,7 @@ func Test_service_StartProcess34(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3460,7 +3464,7 @@ func Test_service_StartProcess35(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3561,7 +3565,7 @@ func Test_service_StartProcess36(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3662,7 +3666,7 @@ func Test_service_StartProcess37(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3763,7 +3767,7 @@ func Test_service_StartProcess38(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3864,7 +3868,7 @@ func Test_service_StartProcess39(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -3965,7 +3969,7 @@ func Test_service_StartProcess41(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4066,7 +4070,7 @@ func Test_service_StartProcess42(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4167,7 +4171,7 @@ func Test_service_StartProcess43(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4268,7 +4272,7 @@ func Test_service_StartProcess44(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4369,7 +4373,7 @@ func Test_service_StartProcess45(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4470,7 +4474,7 @@ func Test_service_StartProcess46(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4571,7 +4575,7 @@ func Test_service_StartProcess47(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4672,7 +4676,7 @@ func Test_service_StartProcess48(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4773,7 +4777,7 @@ func Test_service_StartProcess49(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4874,7 +4878,7 @@ func Test_service_StartProcess50(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -4975,7 +4979,7 @@ func Test_service_StartProcess51(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5076,7 +5080,7 @@ func Test_service_StartProcess52(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5177,7 +5181,7 @@ func Test_service_StartProcess53(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5278,7 +5282,7 @@ func Test_service_StartProcess54(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5379,7 +5383,7 @@ func Test_service_StartProcess55(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5480,7 +5484,7 @@ func Test_service_StartProcess56(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5581,7 +5585,7 @@ func Test_service_StartProcess57(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5682,7 +5686,7 @@ func Test_service_StartProcess58(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5783,7 +5787,7 @@ func Test_service_StartProcess59(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5884,7 +5888,7 @@ func Test_service_StartProcess60(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -5985,7 +5989,7 @@ func Test_service_StartProcess61(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6086,7 +6090,7 @@ func Test_service_StartProcess62(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6187,7 +6191,7 @@ func Test_service_StartProcess63(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6288,7 +6292,7 @@ func Test_service_StartProcess64(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6389,7 +6393,7 @@ func Test_service_StartProcess65(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6490,7 +6494,7 @@ func Test_service_StartProcess66(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6591,7 +6595,7 @@ func Test_service_StartProcess67(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6692,7 +6696,7 @@ func Test_service_StartProcess68(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6793,7 +6797,7 @@ func Test_service_StartProcess69(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6894,7 +6898,7 @@ func Test_service_StartProcess70(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -6995,7 +6999,7 @@ func Test_service_StartProcess71(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7096,7 +7100,7 @@ func Test_service_StartProcess72(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7197,7 +7201,7 @@ func Test_service_StartProcess73(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7298,7 +7302,7 @@ func Test_service_StartProcess74(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7399,7 +7403,7 @@ func Test_service_StartProcess75(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7500,7 +7504,7 @@ func Test_service_StartProcess76(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7601,7 +7605,7 @@ func Test_service_StartProcess77(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7702,7 +7706,7 @@ func Test_service_StartProcess78(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7803,7 +7807,7 @@ func Test_service_StartProcess79(t *testing.T) {
 						Times(1),
 					f.httpClient.
 						EXPECT().
-						Post(url, "application/json", requestBody).
+						PostWithContext(gomock.Any(), url, "application/json", requestBody).
 						Return(&httpResponse, tt.args.httpError).
 						MaxTimes(1),
 				)
@@ -7820,6 +7824,123 @@ func Test_service_StartProcess79(t *testing.T) {
 	}
 }
 
+func Test_service_StartProcessWithContext(t *testing.T) {
+	type args struct {
+		cancelBeforeStart bool
+		httpError         error
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			name: "Failed--Cancelled-Before-Post",
+			args: args{
+				cancelBeforeStart: true,
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "Failed--Deadline-During-Post",
+			args: args{
+				httpError: context.DeadlineExceeded,
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, service := setupSubtest(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.args.cancelBeforeStart {
+				cancel()
+			}
+
+			f.jsonHandler.
+				EXPECT().
+				Marshal(Request{Key: "value"}).
+				DoAndReturn(marshalMock(false)).
+				Times(1)
+			f.httpClient.
+				EXPECT().
+				PostWithContext(ctx, "https://test.url.com", "application/json", []byte(`{"key":"value"}`)).
+				Return(nil, tt.args.httpError).
+				MaxTimes(1)
+
+			_, err := service.StartProcessWithContext(ctx)
+
+			assert.Assert(t, errors.Is(err, tt.wantErr))
+		})
+	}
+}
+
+func Test_service_StartProcessWithFakeClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		expectedString string
+		wantErr        bool
+	}{
+		{
+			name:       "Failed--Server-Error",
+			statusCode: 500,
+			wantErr:    true,
+		},
+		{
+			name:           "Successful",
+			statusCode:     201,
+			expectedString: `{"key":"value"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewFakeClient()
+			client.On(http.MethodPost, "https://test.url.com").Respond(tt.statusCode, tt.expectedString)
+			service := NewService(client, errorUtil.NewErrorUtil(), util.InfrastructureConfig{}, jsonLib.NewJSONHandler())
+
+			response, err := service.StartProcess()
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.expectedString, response)
+			calls := client.CallsTo(http.MethodPost, "https://test.url.com")
+			assert.Equal(t, 1, len(calls))
+			assert.Equal(t, `{"key":"value"}`, string(calls[0].Body))
+		})
+	}
+}
+
+func Test_service_StartProcessWithResponseLimit(t *testing.T) {
+	client := fake.NewFakeClient()
+	client.On(http.MethodPost, "https://test.url.com").Respond(201, strings.Repeat(`{"key":"value"}`, 100))
+	limited := responselimit.NewResponseLimitClient(client, errorUtil.NewErrorUtil(), util.InfrastructureConfig{HttpMaxResponseBytes: 1024})
+	service := NewService(limited, errorUtil.NewErrorUtil(), util.InfrastructureConfig{}, jsonLib.NewJSONHandler())
+
+	response, err := service.StartProcess()
+
+	var tooLarge *responselimit.TooLargeError
+	assert.Assert(t, errors.As(err, &tooLarge))
+	assert.Equal(t, "", response)
+}
+
+func Test_service_StartProcessWithServiceURL(t *testing.T) {
+	client := fake.NewFakeClient()
+	client.On(http.MethodPost, "https://service.url.com/items").Respond(201, `{"key":"value"}`)
+	config := util.InfrastructureConfig{ServiceURL: "https://service.url.com/items"}
+	service := NewService(client, errorUtil.NewErrorUtil(), config, jsonLib.NewJSONHandler())
+
+	response, err := service.StartProcess()
+
+	assert.NilError(t, err)
+	assert.Equal(t, `{"key":"value"}`, response)
+	assert.Equal(t, 1, len(client.CallsTo(http.MethodPost, "https://service.url.com/items")))
+}
+
 type ErrorBuffer struct {
 }
 
@@ -2,11 +2,142 @@
 package util
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type InfrastructureConfig struct {
 	ConfigName string `mapstructure:"CONFIG_NAME"`
+	// ServiceURL is where the service posts its requests to, https://test.url.com if not set
+	ServiceURL string `mapstructure:"SERVICE_URL"`
+
+	// ShutdownTimeout is how long in-flight requests may take to finish when the application is stopped
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+
+	// HttpMaxResponseBytes caps the size of response bodies, requests may lower or raise it for themselves
+	HttpMaxResponseBytes int64 `mapstructure:"HTTP_MAX_RESPONSE_BYTES"`
+
+	// Transport of the outbound HttpClient, zero values keep the defaults of net/http.
+	// HttpKeepAlive is the TCP keep-alive period, HttpDisableKeepAlives turns off connection reuse altogether.
+	// Without HttpProxy the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+	HttpTimeout               time.Duration `mapstructure:"HTTP_TIMEOUT"`
+	HttpDialTimeout           time.Duration `mapstructure:"HTTP_DIAL_TIMEOUT"`
+	HttpTLSHandshakeTimeout   time.Duration `mapstructure:"HTTP_TLS_HANDSHAKE_TIMEOUT"`
+	HttpResponseHeaderTimeout time.Duration `mapstructure:"HTTP_RESPONSE_HEADER_TIMEOUT"`
+	HttpIdleConnTimeout       time.Duration `mapstructure:"HTTP_IDLE_CONN_TIMEOUT"`
+	HttpKeepAlive             time.Duration `mapstructure:"HTTP_KEEP_ALIVE"`
+	HttpDisableKeepAlives     bool          `mapstructure:"HTTP_DISABLE_KEEP_ALIVES"`
+	HttpMaxIdleConns          int           `mapstructure:"HTTP_MAX_IDLE_CONNS"`
+	HttpMaxIdleConnsPerHost   int           `mapstructure:"HTTP_MAX_IDLE_CONNS_PER_HOST"`
+	HttpMaxConnsPerHost       int           `mapstructure:"HTTP_MAX_CONNS_PER_HOST"`
+	HttpProxy                 string        `mapstructure:"HTTP_CLIENT_PROXY"`
+	HttpNoProxy               []string      `mapstructure:"HTTP_CLIENT_NO_PROXY"`
+	HttpDisableHTTP2          bool          `mapstructure:"HTTP_DISABLE_HTTP2"`
+
+	// Egress policy of the outbound HttpClient, on if HttpEgressPolicy is set or one of the lists isn't empty.
+	// Entries are host names, "*.example.com" wildcards, IPs or CIDR ranges. Loopback, link-local and private
+	// addresses are denied unless HttpEgressAllowPrivate is set or their range is in HttpEgressAllow.
+	HttpEgressPolicy       bool     `mapstructure:"HTTP_EGRESS_POLICY"`
+	HttpEgressAllow        []string `mapstructure:"HTTP_EGRESS_ALLOW"`
+	HttpEgressDeny         []string `mapstructure:"HTTP_EGRESS_DENY"`
+	HttpEgressAllowPrivate bool     `mapstructure:"HTTP_EGRESS_ALLOW_PRIVATE"`
+
+	// Redirects and cookies of the outbound HttpClient. HttpMaxRedirects replaces the default of 10 and
+	// HttpRedirectAuth is "keep" or "strip" for the Authorization and Cookie headers on redirects to other hosts.
+	// Cookies are kept in memory with HttpCookieJar, or in HttpCookieJarFile to survive restarts.
+	HttpMaxRedirects            int    `mapstructure:"HTTP_MAX_REDIRECTS"`
+	HttpDisableRedirects        bool   `mapstructure:"HTTP_DISABLE_REDIRECTS"`
+	HttpRedirectAuth            string `mapstructure:"HTTP_REDIRECT_AUTH"`
+	HttpRedirectForbidDowngrade bool   `mapstructure:"HTTP_REDIRECT_FORBID_DOWNGRADE"`
+	HttpCookieJar               bool   `mapstructure:"HTTP_COOKIE_JAR"`
+	HttpCookieJarFile           string `mapstructure:"HTTP_COOKIE_JAR_FILE"`
+
+	// HttpUnixSockets are "host=path" entries sending the requests for host to a Unix domain socket, also
+	// reachable as "unix://host/...". HttpH2C speaks HTTP/2 over cleartext to all plain HTTP hosts.
+	HttpUnixSockets []string `mapstructure:"HTTP_UNIX_SOCKETS"`
+	HttpH2C         bool     `mapstructure:"HTTP_H2C"`
+
+	// DNS resolution of the outbound HttpClient. Answers are cached with HttpDNSCache or one of the other settings,
+	// for their TTL if they come from HttpDNSServer ("host:port"), for HttpDNSCacheTTL if from the system resolver.
+	// HttpDNSHosts are "host=ip" overrides like in /etc/hosts, repeat the host for several addresses.
+	HttpDNSCache    bool          `mapstructure:"HTTP_DNS_CACHE"`
+	HttpDNSServer   string        `mapstructure:"HTTP_DNS_SERVER"`
+	HttpDNSHosts    []string      `mapstructure:"HTTP_DNS_HOSTS"`
+	HttpDNSCacheTTL time.Duration `mapstructure:"HTTP_DNS_CACHE_TTL"`
+
+	// TLS of the outbound HttpClient. The CA files are added to the system pool, the client certificate is reloaded
+	// when its files change. HttpTLSMinVersion is e.g. "1.2", cipher suites use the Go names like
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". HttpTLSPins are "host=pin" entries, with pin being the base64 encoded
+	// SHA-256 of the certificate's public key, repeat the host for backup pins.
+	HttpTLSCAFiles      []string `mapstructure:"HTTP_TLS_CA_FILES"`
+	HttpTLSClientCert   string   `mapstructure:"HTTP_TLS_CLIENT_CERT"`
+	HttpTLSClientKey    string   `mapstructure:"HTTP_TLS_CLIENT_KEY"`
+	HttpTLSMinVersion   string   `mapstructure:"HTTP_TLS_MIN_VERSION"`
+	HttpTLSCipherSuites []string `mapstructure:"HTTP_TLS_CIPHER_SUITES"`
+	HttpTLSPins         []string `mapstructure:"HTTP_TLS_PINS"`
+
+	// Credentials attached to outbound requests. AuthType is one of "bearer", "basic", "api_key" or "oauth2",
+	// the latter uses the client credentials flow against AuthTokenURL.
+	AuthType         string   `mapstructure:"AUTH_TYPE"`
+	AuthToken        string   `mapstructure:"AUTH_TOKEN"`
+	AuthUsername     string   `mapstructure:"AUTH_USERNAME"`
+	AuthPassword     string   `mapstructure:"AUTH_PASSWORD"`
+	AuthAPIKeyHeader string   `mapstructure:"AUTH_API_KEY_HEADER"`
+	AuthAPIKey       string   `mapstructure:"AUTH_API_KEY"`
+	AuthTokenURL     string   `mapstructure:"AUTH_TOKEN_URL"`
+	AuthClientID     string   `mapstructure:"AUTH_CLIENT_ID"`
+	AuthClientSecret string   `mapstructure:"AUTH_CLIENT_SECRET"`
+	AuthScopes       []string `mapstructure:"AUTH_SCOPES"`
+
+	// Circuit breaker around the outbound HttpClient, zero values fall back to the defaults of the breaker
+	CircuitBreakerFailureRate         float64       `mapstructure:"CIRCUIT_BREAKER_FAILURE_RATE"`
+	CircuitBreakerConsecutiveFailures int           `mapstructure:"CIRCUIT_BREAKER_CONSECUTIVE_FAILURES"`
+	CircuitBreakerWindowSize          int           `mapstructure:"CIRCUIT_BREAKER_WINDOW_SIZE"`
+	CircuitBreakerMinRequests         int           `mapstructure:"CIRCUIT_BREAKER_MIN_REQUESTS"`
+	CircuitBreakerOpenTimeout         time.Duration `mapstructure:"CIRCUIT_BREAKER_OPEN_TIMEOUT"`
+	CircuitBreakerHalfOpenRequests    int           `mapstructure:"CIRCUIT_BREAKER_HALF_OPEN_REQUESTS"`
+
+	// Per host limits as comma separated "host=value" entries, the host "*" applies to all hosts without an entry.
+	// RateLimits values are "requestsPerSecond:burst", MaxInFlightPerHost values are the number of concurrent requests.
+	// The queue sizes limit how many requests wait per host, 100 if not set, a negative size fails them right away.
+	RateLimits         []string `mapstructure:"RATE_LIMITS"`
+	RateLimitQueueSize int      `mapstructure:"RATE_LIMIT_QUEUE_SIZE"`
+	MaxInFlightPerHost []string `mapstructure:"MAX_IN_FLIGHT_PER_HOST"`
+	InFlightQueueSize  int      `mapstructure:"IN_FLIGHT_QUEUE_SIZE"`
+
+	// Load balancing across replicas of the downstream service. LoadBalancerBackends are base URLs, optionally
+	// weighted as "url=weight". LoadBalancerStrategy is "round_robin", "least_outstanding" or "weighted".
+	// Backends are probed with a GET of LoadBalancerHealthPath, if set, and ejected or let back in after the
+	// given number of consecutive failed or successful probes.
+	LoadBalancerBackends           []string      `mapstructure:"LOAD_BALANCER_BACKENDS"`
+	LoadBalancerStrategy           string        `mapstructure:"LOAD_BALANCER_STRATEGY"`
+	LoadBalancerHealthPath         string        `mapstructure:"LOAD_BALANCER_HEALTH_PATH"`
+	LoadBalancerHealthInterval     time.Duration `mapstructure:"LOAD_BALANCER_HEALTH_INTERVAL"`
+	LoadBalancerHealthTimeout      time.Duration `mapstructure:"LOAD_BALANCER_HEALTH_TIMEOUT"`
+	LoadBalancerUnhealthyThreshold int           `mapstructure:"LOAD_BALANCER_UNHEALTHY_THRESHOLD"`
+	LoadBalancerHealthyThreshold   int           `mapstructure:"LOAD_BALANCER_HEALTHY_THRESHOLD"`
+
+	// Hedging of idempotent requests. A copy is sent after HedgeDelay without a response, or after the
+	// HedgePercentile, e.g. 0.95, of the observed latencies once known. HedgeMaxHedges caps the copies per request.
+	HedgeDelay      time.Duration `mapstructure:"HEDGE_DELAY"`
+	HedgePercentile float64       `mapstructure:"HEDGE_PERCENTILE"`
+	HedgeMaxHedges  int           `mapstructure:"HEDGE_MAX_HEDGES"`
+
+	// Compression of outbound requests, HttpCompressRequests is "gzip" or "zstd", bodies shorter than
+	// HttpCompressMinBytes are sent as they are. HttpDecompressMaxBytes caps the decompressed size of responses.
+	HttpCompressRequests   string `mapstructure:"HTTP_COMPRESS_REQUESTS"`
+	HttpCompressMinBytes   int    `mapstructure:"HTTP_COMPRESS_MIN_BYTES"`
+	HttpDecompressMaxBytes int64  `mapstructure:"HTTP_DECOMPRESS_MAX_BYTES"`
+
+	// Logging of outbound requests, bodies are only logged if HttpLogBodyBytes is set.
+	// The redaction lists extend the defaults, JSON fields are dot separated paths like "user.password". The last
+	// part of every path is also redacted as query parameter, form field and JSON key at any depth, next to
+	// defaults like "client_secret" and "access_token".
+	HttpLogHeaders          bool     `mapstructure:"HTTP_LOG_HEADERS"`
+	HttpLogBodyBytes        int      `mapstructure:"HTTP_LOG_BODY_BYTES"`
+	HttpLogRedactHeaders    []string `mapstructure:"HTTP_LOG_REDACT_HEADERS"`
+	HttpLogRedactJSONFields []string `mapstructure:"HTTP_LOG_REDACT_JSON_FIELDS"`
 }
 
 func LoadInfrastructureConfig() (config InfrastructureConfig, err error) {